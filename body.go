@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/tidwall/sjson"
+)
+
+// Body opens a new reader over a request body
+type Body func() (io.ReadCloser, error)
+
+// BodyMode tells how the body request items are encoded
+type BodyMode int
+
+const (
+	JSONMode BodyMode = iota
+	FormMode
+	MultipartMode
+)
+
+// BytesBody returns a Body that reads content
+func BytesBody(content []byte) Body {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+}
+
+// FileBody returns a Body that streams the file at path and the size of the file
+func FileBody(path string) (Body, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.IsDir() {
+		return nil, 0, fmt.Errorf("%s is a directory", path)
+	}
+
+	body := func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+	return body, info.Size(), nil
+}
+
+// ReaderBody returns a Body that streams r.
+// Since r can't be rewound the body can be opened only once.
+func ReaderBody(r io.Reader) Body {
+	var opened atomic.Bool
+	return func() (io.ReadCloser, error) {
+		if opened.Swap(true) {
+			return nil, bodyConsumedErrMsg
+		}
+		return io.NopCloser(r), nil
+	}
+}
+
+// RawBody is a prebuilt request body given on the command line
+type RawBody struct {
+	Body          Body
+	ContentLength int64
+	ContentType   string
+}
+
+// NewRawBody returns the body given inline with raw, in bodyFile or, if both are empty, through stdin.
+// Stdin is streamed unless replay is set, then it is buffered so that the body can be sent more than once.
+// The Content-Type is contentType or, if empty, JSON or the type guessed from the file extension.
+func NewRawBody(raw string, bodyFile string, contentType string, replay bool) (*RawBody, error) {
+	rawBody := RawBody{ContentType: contentType}
+	if rawBody.ContentType == "" {
+		rawBody.ContentType = ContentType
+	}
+
+	switch {
+	case raw != "" && bodyFile != "":
+		return nil, manyBodiesErrMsg
+	case raw != "":
+		rawBody.Body = BytesBody([]byte(raw))
+		rawBody.ContentLength = int64(len(raw))
+	case bodyFile != "":
+		var err error
+		rawBody.Body, rawBody.ContentLength, err = FileBody(bodyFile)
+		if err != nil {
+			return nil, err
+		}
+		if guessed := mime.TypeByExtension(filepath.Ext(bodyFile)); contentType == "" && guessed != "" {
+			rawBody.ContentType = guessed
+		}
+	case replay:
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		rawBody.Body = BytesBody(content)
+		rawBody.ContentLength = int64(len(content))
+	default:
+		rawBody.Body = ReaderBody(os.Stdin)
+		rawBody.ContentLength = -1
+	}
+
+	return &rawBody, nil
+}
+
+// StdinPiped reports whether stdin is a pipe or a file instead of a terminal
+func StdinPiped() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice == 0
+}
+
+// Field is a request item that ends up in the request body
+type Field struct {
+	Key   string
+	Sep   string
+	Value string
+}
+
+// Resolve returns the value of the field and whether it is raw JSON.
+// The value of file fields is the content of the file.
+func (field Field) Resolve() (string, bool, error) {
+	value := field.Value
+
+	if field.Sep == SepDataFile || field.Sep == SepJSONFile {
+		content, err := os.ReadFile(value)
+		if err != nil {
+			return "", false, err
+		}
+		value = string(content)
+	}
+
+	if field.Sep == SepJSON || field.Sep == SepJSONFile {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(value)); err != nil {
+			return "", false, fmt.Errorf("%w: %s: %v", invalidJSONErrMsg, field.Key, err)
+		}
+		return compact.String(), true, nil
+	}
+
+	return value, false, nil
+}
+
+// JSONBody turns the fields into a JSON object.
+// Keys can be nested paths like a.b[0].c, a[] appends to an array.
+func JSONBody(fields []Field) ([]byte, error) {
+	body := "{}"
+
+	for _, field := range fields {
+		path, err := jsonPath(field.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, raw, err := field.Resolve()
+		if err != nil {
+			return nil, err
+		}
+
+		if raw {
+			body, err = sjson.SetRaw(body, path, value)
+		} else {
+			body, err = sjson.Set(body, path, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(body), nil
+}
+
+// FormBody url-encodes the fields as a form
+func FormBody(fields []Field) ([]byte, error) {
+	form := make(url.Values)
+
+	for _, field := range fields {
+		value, raw, err := field.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		if raw {
+			return nil, formJSONErrMsg
+		}
+		form.Add(field.Key, value)
+	}
+
+	return []byte(form.Encode()), nil
+}
+
+// MultipartBody returns a Body that streams the fields and the files as multipart/form-data
+// and the Content-Type of the body
+func MultipartBody(fields []Field, files []FileField) (Body, string, error) {
+	values := make([][2]string, 0, len(fields))
+	for _, field := range fields {
+		value, raw, err := field.Resolve()
+		if err != nil {
+			return nil, "", err
+		}
+		if raw {
+			return nil, "", formJSONErrMsg
+		}
+		values = append(values, [2]string{field.Key, value})
+	}
+
+	// Report missing files before the request is sent
+	for _, file := range files {
+		if _, err := os.Stat(file.Path); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// Every time the body is opened it must have the boundary of the Content-Type
+	boundaryWriter := multipart.NewWriter(io.Discard)
+	boundary := boundaryWriter.Boundary()
+
+	body := func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			writer := multipart.NewWriter(pw)
+			err := writer.SetBoundary(boundary)
+			if err == nil {
+				err = writeMultipart(writer, values, files)
+			}
+			_ = pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+
+	return body, boundaryWriter.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// writeMultipart writes the fields and then the files to writer
+func writeMultipart(writer *multipart.Writer, values [][2]string, files []FileField) error {
+	for _, value := range values {
+		if err := writer.WriteField(value[0], value[1]); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		filename := file.Filename
+		if filename == "" {
+			filename = filepath.Base(file.Path)
+		}
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(file.Path))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(file.Key), quoteEscaper.Replace(filename)))
+		header.Set("Content-Type", contentType)
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(part, file.Path); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// copyFile copies the content of the file at path to w
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// jsonPath converts a key like a.b[0].c to the equivalent sjson path
+func jsonPath(key string) (string, error) {
+	if key == "" || key[0] == '[' || key[0] == '.' {
+		return "", invalidSyntaxErrMsg
+	}
+
+	var segments []string
+	for i := 0; i < len(key); {
+		if key[i] == '[' {
+			end := strings.IndexByte(key[i:], ']')
+			if end < 0 {
+				return "", invalidSyntaxErrMsg
+			}
+			index := key[i+1 : i+end]
+			i += end + 1
+
+			if index == "" {
+				// a[] appends to the array
+				segments = append(segments, "-1")
+			} else if _, err := strconv.Atoi(index); err == nil {
+				segments = append(segments, index)
+			} else {
+				segments = append(segments, escapePathKey(index))
+			}
+			continue
+		}
+
+		if key[i] == '.' {
+			i++
+		}
+
+		end := i
+		for end < len(key) && key[end] != '.' && key[end] != '[' {
+			end++
+		}
+		if end == i {
+			return "", invalidSyntaxErrMsg
+		}
+		segments = append(segments, escapePathKey(key[i:end]))
+		i = end
+	}
+
+	return strings.Join(segments, "."), nil
+}
+
+// escapePathKey escapes the sjson path syntax so that name is used as an object key
+func escapePathKey(name string) string {
+	var escaped strings.Builder
+
+	// Numeric keys would create an array
+	if _, err := strconv.Atoi(name); err == nil {
+		escaped.WriteByte(':')
+	}
+
+	for _, r := range name {
+		if r < utf8.RuneSelf && !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+
+	return escaped.String()
+}
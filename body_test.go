@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONBodyTypedValues(t *testing.T) {
+	items, err := ParseItems([]string{"name=please", "count:=42", "enabled:=true", "tags:=[\"a\",\"b\"]", "meta:={\"x\": 1}", "expr=a=b"})
+	assert.NoError(t, err)
+
+	body, err := JSONBody(items.Data)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"please","count":42,"enabled":true,"tags":["a","b"],"meta":{"x":1},"expr":"a=b"}`, string(body))
+}
+
+func TestJSONBodyNestedPaths(t *testing.T) {
+	items, err := ParseItems([]string{"a.b[0].c=value", "a.b[0].d:=1", "list[]=x", "list[]=y", "obj[key]=v", "codes.404=missing", "dotted[a.b]=c"})
+	assert.NoError(t, err)
+
+	body, err := JSONBody(items.Data)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":{"b":[{"c":"value","d":1}]},"list":["x","y"],"obj":{"key":"v"},"codes":{"404":"missing"},"dotted":{"a.b":"c"}}`, string(body))
+}
+
+func TestJSONBodyFiles(t *testing.T) {
+	dir := t.TempDir()
+	textFile := filepath.Join(dir, "notes.txt")
+	jsonFile := filepath.Join(dir, "config.json")
+	assert.NoError(t, os.WriteFile(textFile, []byte("hello\nworld"), 0o644))
+	assert.NoError(t, os.WriteFile(jsonFile, []byte("{\n  \"debug\": true\n}\n"), 0o644))
+
+	items, err := ParseItems([]string{"text=@" + textFile, "config:=@" + jsonFile})
+	assert.NoError(t, err)
+
+	body, err := JSONBody(items.Data)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"text":"hello\nworld","config":{"debug":true}}`, string(body))
+}
+
+func TestJSONBodyInvalidJSON(t *testing.T) {
+	items, err := ParseItems([]string{"count:=forty-two"})
+	assert.NoError(t, err)
+
+	_, err = JSONBody(items.Data)
+
+	assert.ErrorIs(t, err, invalidJSONErrMsg)
+}
+
+func TestJSONBodyInvalidPath(t *testing.T) {
+	for _, key := range []string{"a[0", "a..b", "[0]", "a."} {
+		_, err := JSONBody([]Field{{Key: key, Sep: SepData, Value: "v"}})
+
+		assert.ErrorIs(t, err, invalidSyntaxErrMsg, key)
+	}
+}
+
+func TestNewRawBodyInline(t *testing.T) {
+	rawBody, err := NewRawBody(`{"raw": true}`, "", "", false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(13), rawBody.ContentLength)
+	assert.Equal(t, ContentType, rawBody.ContentType)
+}
+
+func TestNewRawBodyFile(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "page.html")
+	assert.NoError(t, os.WriteFile(bodyFile, []byte("<h1>please</h1>"), 0o644))
+
+	rawBody, err := NewRawBody("", bodyFile, "", false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(15), rawBody.ContentLength)
+	assert.Equal(t, "text/html; charset=utf-8", rawBody.ContentType)
+
+	rawBody, err = NewRawBody("", bodyFile, "text/plain", false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain", rawBody.ContentType)
+}
+
+func TestNewRawBodyTwice(t *testing.T) {
+	_, err := NewRawBody("{}", "body.json", "", false)
+
+	assert.ErrorIs(t, err, manyBodiesErrMsg)
+}
+
+func TestReaderBodyOpenedOnce(t *testing.T) {
+	body := ReaderBody(strings.NewReader("stream"))
+
+	_, err := body()
+	assert.NoError(t, err)
+
+	_, err = body()
+	assert.ErrorIs(t, err, bodyConsumedErrMsg)
+}
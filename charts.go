@@ -1,10 +1,13 @@
 package main
 
 import (
-	"fmt"
-	"io"
+	"encoding/json"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/components"
@@ -12,6 +15,109 @@ import (
 	"github.com/go-echarts/go-echarts/v2/types"
 )
 
+// histogramBins is the number of bars of the latency histogram
+const histogramBins = 30
+
+// chartPercentiles are the points of the percentile distribution curve
+var chartPercentiles = []float64{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 95, 97.5, 99, 99.5, 99.9, 100}
+
+// DefaultChartTitle is the title of the charts page when ChartOptions.Title is empty
+const DefaultChartTitle = "Requests response's time stats"
+
+// ChartOptions tells where and how the charts are written
+type ChartOptions struct {
+	// Out is the path of the html page, or the directory where it's written.
+	// The page is named stats-<timestamp>.html if Out is empty or a directory.
+	Out   string
+	Title string
+	// JSON also writes the chart data next to the page, with the .json extension
+	JSON bool
+}
+
+// Sample is what the charts keep of a response
+type Sample struct {
+	Index      int
+	StartTime  time.Time
+	RespTime   int64
+	StatusCode int
+	Timings    Timings
+	Lag        time.Duration
+}
+
+// sampleJSON is the serialised sample, durations are in milliseconds
+type sampleJSON struct {
+	Index      int                `json:"index"`
+	StartTime  time.Time          `json:"start-time"`
+	StatusCode int                `json:"status-code"`
+	RespTime   float64            `json:"response-time-ms"`
+	Lag        float64            `json:"lag-ms"`
+	Timings    map[string]float64 `json:"timings-ms"`
+}
+
+func (sample Sample) MarshalJSON() ([]byte, error) {
+	return json.Marshal(sampleJSON{
+		Index:      sample.Index,
+		StartTime:  sample.StartTime,
+		StatusCode: sample.StatusCode,
+		RespTime:   milliseconds(sample.Timings.Total + sample.Lag),
+		Lag:        milliseconds(sample.Lag),
+		Timings: map[string]float64{
+			"dns-lookup":    milliseconds(sample.Timings.DNSLookup),
+			"tcp-connect":   milliseconds(sample.Timings.TCPConnect),
+			"tls-handshake": milliseconds(sample.Timings.TLSHandshake),
+			"ttfb":          milliseconds(sample.Timings.TTFB),
+			"transfer":      milliseconds(sample.Timings.Transfer),
+			"total":         milliseconds(sample.Timings.Total),
+		},
+	})
+}
+
+func (sample *Sample) UnmarshalJSON(data []byte) error {
+	var serialised sampleJSON
+	if err := json.Unmarshal(data, &serialised); err != nil {
+		return err
+	}
+
+	duration := func(name string) time.Duration {
+		return time.Duration(serialised.Timings[name] * float64(time.Millisecond))
+	}
+	*sample = Sample{
+		Index:      serialised.Index,
+		StartTime:  serialised.StartTime,
+		StatusCode: serialised.StatusCode,
+		Lag:        time.Duration(serialised.Lag * float64(time.Millisecond)),
+		Timings: Timings{
+			DNSLookup:    duration("dns-lookup"),
+			TCPConnect:   duration("tcp-connect"),
+			TLSHandshake: duration("tls-handshake"),
+			TTFB:         duration("ttfb"),
+			Transfer:     duration("transfer"),
+			Total:        duration("total"),
+		},
+	}
+	sample.RespTime = (sample.Timings.Total + sample.Lag).Milliseconds()
+	return nil
+}
+
+// ChartData is the data the charts are built from
+type ChartData struct {
+	Title   string   `json:"title"`
+	Samples []Sample `json:"samples"`
+	Summary Summary  `json:"summary"`
+}
+
+// NewSample returns the sample of the response to the request number index
+func NewSample(index int, results Results) Sample {
+	return Sample{
+		Index:      index,
+		StartTime:  results.StartTime,
+		RespTime:   results.RespTime,
+		StatusCode: results.StatusCode,
+		Timings:    results.Timings,
+		Lag:        results.Lag,
+	}
+}
+
 func GenerateLineItems(repetitions int, respTimes []int64) []opts.LineData {
 	items := make([]opts.LineData, 0)
 	for i := 0; i < repetitions; i++ {
@@ -20,12 +126,12 @@ func GenerateLineItems(repetitions int, respTimes []int64) []opts.LineData {
 	return items
 }
 
-func LineShowLabel(repetitions int, requests []string, respTimes []int64) *charts.Line {
+func LineShowLabel(title string, repetitions int, requests []string, respTimes []int64) *charts.Line {
 	line := charts.NewLine()
 	line.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{Theme: types.ThemeMacarons}),
 		charts.WithTitleOpts(opts.Title{
-			Title:    "Requests response's time stats",
+			Title:    title,
 			Subtitle: "",
 		}),
 	)
@@ -43,23 +149,298 @@ func LineShowLabel(repetitions int, requests []string, respTimes []int64) *chart
 	return line
 }
 
-func GenCharts(repetitions int, respTimes []int64) {
-	var requestNumbers []string
+// LatencyHistogram charts how many responses fall in equal ranges of response time
+func LatencyHistogram(histogram *Histogram) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{Theme: types.ThemeMacarons}),
+		charts.WithTitleOpts(opts.Title{Title: "Latency histogram"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithXAxisOpts(opts.XAxis{Name: "ms"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "responses"}),
+	)
 
-	for i := 0; i < repetitions; i++ {
-		requestNumbers = append(requestNumbers, "response "+strconv.Itoa(i+1))
+	labels, counts := HistogramBins(histogram, histogramBins)
+	items := make([]opts.BarData, 0, len(counts))
+	for _, count := range counts {
+		items = append(items, opts.BarData{Value: count})
+	}
+
+	bar.SetXAxis(labels).AddSeries("Responses", items)
+	return bar
+}
+
+// HistogramBins groups the values of histogram in n ranges of the same width from its min to its max,
+// the labels are the ranges lower bounds in milliseconds
+func HistogramBins(histogram *Histogram, n int) ([]string, []int64) {
+	if histogram.Count() == 0 {
+		return nil, nil
+	}
+
+	from, to := histogram.Min(), histogram.Max()
+	width := (to - from) / time.Duration(n)
+	if width < time.Microsecond {
+		n, width = 1, max(to-from, time.Microsecond)
+	}
+
+	labels := make([]string, n)
+	for i := range labels {
+		labels[i] = strconv.FormatFloat(milliseconds(from+time.Duration(i)*width), 'f', 3, 64)
+	}
+
+	counts := make([]int64, n)
+	for _, bucket := range histogram.Buckets() {
+		middle := min(max(bucket.From+(bucket.To-bucket.From)/2, from), to)
+		counts[min(int((middle-from)/width), n-1)] += bucket.Count
+	}
+	return labels, counts
+}
+
+// PercentileCurve charts the response time of every percentile of chartPercentiles
+func PercentileCurve(histogram *Histogram) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{Theme: types.ThemeMacarons}),
+		charts.WithTitleOpts(opts.Title{Title: "Percentile distribution"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "ms"}),
+	)
+
+	labels := make([]string, 0, len(chartPercentiles))
+	items := make([]opts.LineData, 0, len(chartPercentiles))
+	for _, p := range chartPercentiles {
+		labels = append(labels, "p"+strconv.FormatFloat(p, 'f', -1, 64))
+		items = append(items, opts.LineData{Value: milliseconds(histogram.ValueAtPercentile(p))})
+	}
+
+	line.SetXAxis(labels).
+		AddSeries("Response time (ms)", items).
+		SetSeriesOptions(charts.WithLineChartOpts(opts.LineChart{Smooth: true, ShowSymbol: true}))
+	return line
+}
+
+// StatusPie charts the share of every status code
+func StatusPie(statusCodes map[int]int) *charts.Pie {
+	pie := charts.NewPie()
+	pie.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{Theme: types.ThemeMacarons}),
+		charts.WithTitleOpts(opts.Title{Title: "Status codes"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "item"}),
+	)
+
+	codes := make([]int, 0, len(statusCodes))
+	for statusCode := range statusCodes {
+		codes = append(codes, statusCode)
+	}
+	sort.Ints(codes)
+
+	items := make([]opts.PieData, 0, len(codes))
+	for _, statusCode := range codes {
+		items = append(items, opts.PieData{Name: strconv.Itoa(statusCode), Value: statusCodes[statusCode]})
+	}
+
+	pie.AddSeries("Status codes", items).
+		SetSeriesOptions(charts.WithLabelOpts(opts.Label{Show: true, Formatter: "{b}: {c}"}))
+	return pie
+}
+
+// ThroughputOverTime charts the number of responses per second received during the run
+func ThroughputOverTime(samples []Sample) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{Theme: types.ThemeMacarons}),
+		charts.WithTitleOpts(opts.Title{Title: "Throughput over time"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "req/s"}),
+	)
+
+	labels, rates := ThroughputIntervals(samples)
+	items := make([]opts.LineData, 0, len(rates))
+	for _, rate := range rates {
+		items = append(items, opts.LineData{Value: rate})
+	}
+
+	line.SetXAxis(labels).
+		AddSeries("Throughput (req/s)", items).
+		SetSeriesOptions(charts.WithLineChartOpts(opts.LineChart{ShowSymbol: true}))
+	return line
+}
+
+// maxThroughputPoints limits the number of intervals of the throughput chart, e.g. of the whole history
+const maxThroughputPoints = 500
+
+// throughputSteps are the interval lengths of the throughput chart, from the shortest one
+var throughputSteps = []time.Duration{
+	100 * time.Millisecond, time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour,
+}
+
+// throughputInterval returns the shortest step that splits span in at most maxThroughputPoints intervals.
+// Runs shorter than 10s are split in 100ms intervals, longer ones in at least 1s intervals.
+func throughputInterval(span time.Duration) time.Duration {
+	for _, step := range throughputSteps {
+		if step == 100*time.Millisecond && span >= 10*time.Second {
+			continue
+		}
+		if span/step < maxThroughputPoints {
+			return step
+		}
+	}
+	// Spans of more than 500 days are split in whole days
+	days := span/(maxThroughputPoints*24*time.Hour) + 1
+	return days * 24 * time.Hour
+}
+
+// ThroughputIntervals counts the responses received in every interval of the run, per second.
+// Intervals last 100ms if the run lasted less than 10s, 1s or more otherwise, so that there are at most
+// maxThroughputPoints of them. The labels are the interval starts relative to the first request.
+func ThroughputIntervals(samples []Sample) ([]string, []float64) {
+	if len(samples) == 0 {
+		return nil, nil
+	}
+
+	start, end := samples[0].StartTime, samples[0].StartTime
+	for _, sample := range samples {
+		if sample.StartTime.Before(start) {
+			start = sample.StartTime
+		}
+		if done := sample.StartTime.Add(sample.Timings.Total); done.After(end) {
+			end = done
+		}
+	}
+
+	interval := throughputInterval(end.Sub(start))
+
+	counts := make([]int, end.Sub(start)/interval+1)
+	for _, sample := range samples {
+		counts[sample.StartTime.Add(sample.Timings.Total).Sub(start)/interval]++
+	}
+
+	labels := make([]string, len(counts))
+	rates := make([]float64, len(counts))
+	for i, count := range counts {
+		labels[i] = (time.Duration(i) * interval).String()
+		rates[i] = float64(count) / interval.Seconds()
+	}
+	return labels, rates
+}
+
+// PhaseBars stacks the time spent in every phase of each request
+func PhaseBars(samples []Sample) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{Theme: types.ThemeMacarons}),
+		charts.WithTitleOpts(opts.Title{Title: "Request phases"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Right: "0"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "ms"}),
+	)
+
+	requests := make([]string, 0, len(samples))
+	phases := make([][]opts.BarData, 5)
+	for _, sample := range samples {
+		requests = append(requests, "response "+strconv.Itoa(sample.Index))
+
+		timings := sample.Timings
+		waiting := max(timings.TTFB-timings.DNSLookup-timings.TCPConnect-timings.TLSHandshake, 0)
+		for i, phase := range []time.Duration{timings.DNSLookup, timings.TCPConnect, timings.TLSHandshake, waiting, timings.Transfer} {
+			phases[i] = append(phases[i], opts.BarData{Value: milliseconds(phase)})
+		}
+	}
+
+	bar.SetXAxis(requests)
+	for i, name := range []string{"DNS lookup", "TCP connect", "TLS handshake", "Waiting", "Content transfer"} {
+		bar.AddSeries(name, phases[i], charts.WithBarChartOpts(opts.BarChart{Stack: "phases"}))
+	}
+	return bar
+}
+
+// ChartPath returns the path of the charts page written at now
+func (options ChartOptions) ChartPath(now time.Time) string {
+	return options.chartPath("stats", now)
+}
+
+// chartPath returns the path of a page written at now, named prefix-<timestamp>.html by default
+func (options ChartOptions) chartPath(prefix string, now time.Time) string {
+	name := prefix + "-" + now.Format("20060102-150405") + ".html"
+	if options.Out == "" {
+		return name
+	}
+	if info, err := os.Stat(options.Out); err == nil && info.IsDir() {
+		return filepath.Join(options.Out, name)
+	}
+	return options.Out
+}
+
+// GenCharts writes the charts of the responses samples and of the run summary,
+// it returns the path of the page
+func GenCharts(samples []Sample, summary Summary, options ChartOptions) (string, error) {
+	title := options.Title
+	if title == "" {
+		title = DefaultChartTitle
+	}
+
+	repetitions := len(samples)
+	requestNumbers := make([]string, 0, repetitions)
+	respTimes := make([]int64, 0, repetitions)
+	for _, sample := range samples {
+		requestNumbers = append(requestNumbers, "response "+strconv.Itoa(sample.Index))
+		respTimes = append(respTimes, sample.RespTime)
 	}
 
 	page := components.NewPage()
+	page.PageTitle = title
 	page.AddCharts(
-		LineShowLabel(repetitions, requestNumbers, respTimes),
+		LineShowLabel(title, repetitions, requestNumbers, respTimes),
+		LatencyHistogram(summary.Histogram),
+		PercentileCurve(summary.Histogram),
+		StatusPie(summary.StatusCodes),
+		ThroughputOverTime(samples),
+		PhaseBars(samples),
 	)
-	f, err := os.Create("stats.html")
-	if err != nil {
-		panic(err)
+
+	path := options.ChartPath(time.Now())
+	if err := writePage(page, path); err != nil {
+		return "", err
+	}
+
+	if options.JSON {
+		data, err := json.MarshalIndent(ChartData{Title: title, Samples: samples, Summary: summary}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(chartJSONPath(path), append(data, '\n'), 0644); err != nil {
+			return "", err
+		}
+	}
+
+	return path, nil
+}
+
+// chartJSONPath returns the path of the chart data written next to the page at path,
+// a page already named .json keeps its name so that the data doesn't overwrite it
+func chartJSONPath(path string) string {
+	if filepath.Ext(path) == ".json" {
+		return path + ".json"
 	}
-	err = page.Render(io.MultiWriter(f))
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+}
+
+// writePage renders page to path, creating its directory if needed
+func writePage(page *components.Page, path string) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(path)
 	if err != nil {
-		fmt.Printf("response: chart generation error: %v\n", err)
+		return err
+	}
+	if err := page.Render(f); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
 }
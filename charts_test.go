@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramBins(t *testing.T) {
+	histogram := NewHistogram()
+	for i := 0; i < 100; i++ {
+		histogram.Record(time.Duration(1000+i*10) * time.Microsecond)
+	}
+
+	labels, counts := HistogramBins(histogram, 10)
+	assert.Equal(t, []string{"1.000", "1.099", "1.198", "1.297", "1.396", "1.495", "1.594", "1.693", "1.792", "1.891"}, labels)
+	assert.Equal(t, []int64{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, counts)
+
+	single := NewHistogram()
+	single.Record(5 * time.Millisecond)
+	labels, counts = HistogramBins(single, 10)
+	assert.Equal(t, []string{"5.000"}, labels)
+	assert.Equal(t, []int64{1}, counts)
+
+	labels, counts = HistogramBins(NewHistogram(), 10)
+	assert.Empty(t, labels)
+	assert.Empty(t, counts)
+}
+
+func TestThroughputIntervals(t *testing.T) {
+	start := time.Now()
+	var samples []Sample
+	for i := 0; i < 6; i++ {
+		samples = append(samples, Sample{
+			Index:     i + 1,
+			StartTime: start.Add(time.Duration(i) * 50 * time.Millisecond),
+			Timings:   Timings{Total: 10 * time.Millisecond},
+		})
+	}
+
+	labels, rates := ThroughputIntervals(samples)
+	assert.Equal(t, []string{"0s", "100ms", "200ms"}, labels)
+	assert.Equal(t, []float64{20, 20, 20}, rates)
+}
+
+func TestThroughputIntervalsOfLongRuns(t *testing.T) {
+	start := time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC)
+	for span, interval := range map[time.Duration]time.Duration{
+		5 * time.Second:          100 * time.Millisecond,
+		time.Minute:              time.Second,
+		time.Hour:                10 * time.Second,
+		7 * 24 * time.Hour:       30 * time.Minute,
+		365 * 24 * time.Hour:     24 * time.Hour,
+		3 * 365 * 24 * time.Hour: 72 * time.Hour,
+	} {
+		assert.Equal(t, interval, throughputInterval(span), span)
+
+		// The history of a year is charted in a few hundred points, not one per second
+		samples := []Sample{{StartTime: start}, {StartTime: start.Add(span)}}
+		labels, rates := ThroughputIntervals(samples)
+		assert.LessOrEqual(t, len(labels), maxThroughputPoints, span)
+		assert.Len(t, rates, len(labels))
+	}
+}
+
+func TestChartPath(t *testing.T) {
+	now := time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC)
+	dir := t.TempDir()
+
+	assert.Equal(t, "stats-20240309-140507.html", ChartOptions{}.ChartPath(now))
+	assert.Equal(t, filepath.Join(dir, "stats-20240309-140507.html"), ChartOptions{Out: dir}.ChartPath(now))
+	assert.Equal(t, filepath.Join(dir, "run.html"), ChartOptions{Out: filepath.Join(dir, "run.html")}.ChartPath(now))
+}
+
+func TestGenCharts(t *testing.T) {
+	recorder := NewRecorder()
+	var samples []Sample
+	start := time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC)
+	for i := 1; i <= 3; i++ {
+		results := Results{
+			StartTime:  start.Add(time.Duration(i) * time.Millisecond),
+			StatusCode: 200,
+			RespTime:   int64(i),
+			Timings:    Timings{TTFB: time.Duration(i) * time.Millisecond, Total: time.Duration(i) * time.Millisecond},
+		}
+		recorder.Record(Response{Index: i, Results: results})
+		samples = append(samples, NewSample(i, results))
+	}
+
+	out := filepath.Join(t.TempDir(), "charts", "run.html")
+	path, err := GenCharts(samples, recorder.Summary(time.Second), ChartOptions{Out: out, Title: "Smoke test", JSON: true})
+	assert.NoError(t, err)
+	assert.Equal(t, out, path)
+
+	page, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(page), "<title>Smoke test</title>")
+
+	data, err := os.ReadFile(strings.TrimSuffix(out, ".html") + ".json")
+	assert.NoError(t, err)
+	var chartData ChartData
+	assert.NoError(t, json.Unmarshal(data, &chartData))
+	assert.Equal(t, "Smoke test", chartData.Title)
+	assert.Equal(t, samples, chartData.Samples)
+
+	// The data doesn't overwrite a page named .json
+	out = filepath.Join(t.TempDir(), "run.json")
+	path, err = GenCharts(samples, recorder.Summary(time.Second), ChartOptions{Out: out, JSON: true})
+	assert.NoError(t, err)
+	page, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(page), "<html>")
+	data, err = os.ReadFile(out + ".json")
+	assert.NoError(t, err)
+	assert.True(t, json.Valid(data))
+
+	_, err = GenCharts(samples, recorder.Summary(time.Second), ChartOptions{Out: filepath.Join(path, "run.html")})
+	assert.Error(t, err)
+}
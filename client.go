@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ClientOptions holds the settings shared by every request sent by a client
+type ClientOptions struct {
+	// ConnectTimeout limits the time spent opening a connection, TLS handshake included
+	ConnectTimeout time.Duration
+	// Concurrency is the number of requests sent in parallel through the client
+	Concurrency int
+}
+
+// NewClient returns an http client configured with options.
+// The client doesn't follow redirects, they are followed by Do.
+func NewClient(options ClientOptions) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if options.ConnectTimeout > 0 {
+		dialer := &net.Dialer{
+			Timeout:   options.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = options.ConnectTimeout
+	}
+
+	// Keep a connection open for every worker instead of reconnecting
+	if options.Concurrency > http.DefaultMaxIdleConnsPerHost {
+		transport.MaxIdleConnsPerHost = options.Concurrency
+		transport.MaxIdleConns = max(transport.MaxIdleConns, options.Concurrency)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// timeoutReader resets timer after every read
+type timeoutReader struct {
+	reader  io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (r *timeoutReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
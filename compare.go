@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/types"
+	"github.com/pterm/pterm"
+)
+
+// DefaultCompareTitle is the title of the comparison page when ChartOptions.Title is empty
+const DefaultCompareTitle = "Runs comparison"
+
+// Run is the saved data of a run
+type Run struct {
+	Name    string
+	Summary Summary
+	// Samples are missing if the run has been loaded from its summary
+	Samples []Sample
+}
+
+// LoadRun loads a run from its logs (a logs dir, a log file or a JSON Lines file),
+// its --summary-json file or its --chart-json file
+func LoadRun(path string) (Run, error) {
+	run := Run{Name: strings.TrimSuffix(filepath.Base(filepath.Clean(path)), filepath.Ext(path))}
+
+	if filepath.Ext(path) == ".json" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Run{}, err
+		}
+
+		// GenLog logs aren't always valid JSON, they are read as logs if they can't be decoded
+		var saved struct {
+			Samples   []Sample        `json:"samples"`
+			Summary   *Summary        `json:"summary"`
+			Histogram json.RawMessage `json:"histogram"`
+		}
+		if json.Unmarshal(data, &saved) == nil {
+			switch {
+			case saved.Summary != nil:
+				run.Summary, run.Samples = *saved.Summary, saved.Samples
+				return run, nil
+			case saved.Histogram != nil:
+				err := json.Unmarshal(data, &run.Summary)
+				return run, err
+			}
+		}
+	}
+
+	records, err := ReadLogs([]string{path})
+	if err != nil {
+		return Run{}, err
+	}
+	run.Samples, run.Summary = summarizeRecords(records)
+	return run, nil
+}
+
+// Significance tells whether the difference between the mean response times of two runs
+// is unlikely to be due to chance, with Welch's test.
+// The runs are large enough for the normal approximation of the t distribution.
+func Significance(base Summary, other Summary) (float64, bool) {
+	baseCount, otherCount := float64(base.Histogram.Count()), float64(other.Histogram.Count())
+	if baseCount < 2 || otherCount < 2 {
+		return 1, false
+	}
+
+	baseStdDev, otherStdDev := float64(base.StdDev), float64(other.StdDev)
+	standardError := math.Sqrt(baseStdDev*baseStdDev/baseCount + otherStdDev*otherStdDev/otherCount)
+	if standardError == 0 {
+		if base.Mean == other.Mean {
+			return 1, false
+		}
+		return 0, true
+	}
+
+	z := math.Abs(float64(other.Mean-base.Mean)) / standardError
+	p := math.Erfc(z / math.Sqrt2)
+	return p, p < 0.05
+}
+
+// significanceStars marks a p-value as conventionally done in reports
+func significanceStars(p float64) string {
+	switch {
+	case p < 0.001:
+		return "***"
+	case p < 0.01:
+		return "**"
+	case p < 0.05:
+		return "*"
+	}
+	return "ns"
+}
+
+// compareMetric is a row of the comparison table
+type compareMetric struct {
+	name  string
+	value func(summary Summary) float64
+	// lowerIsBetter colours the deltas
+	lowerIsBetter bool
+	format        func(value float64) string
+}
+
+var compareMetrics = []compareMetric{
+	{"Requests", func(s Summary) float64 { return float64(s.Requests) }, false, formatCount},
+	{"Error rate", func(s Summary) float64 { return errorRate(s) }, true, formatPercent},
+	{"Throughput", func(s Summary) float64 { return s.Throughput }, false, formatThroughput},
+	{"Min", func(s Summary) float64 { return milliseconds(s.Min) }, true, formatMilliseconds},
+	{"Mean", func(s Summary) float64 { return milliseconds(s.Mean) }, true, formatMilliseconds},
+	{"Median", func(s Summary) float64 { return milliseconds(s.Median) }, true, formatMilliseconds},
+	{"p90", func(s Summary) float64 { return milliseconds(s.P90) }, true, formatMilliseconds},
+	{"p95", func(s Summary) float64 { return milliseconds(s.P95) }, true, formatMilliseconds},
+	{"p99", func(s Summary) float64 { return milliseconds(s.P99) }, true, formatMilliseconds},
+	{"Max", func(s Summary) float64 { return milliseconds(s.Max) }, true, formatMilliseconds},
+}
+
+func errorRate(summary Summary) float64 {
+	if summary.Requests == 0 {
+		return 0
+	}
+	return float64(summary.Errors) / float64(summary.Requests) * 100
+}
+
+func formatCount(value float64) string        { return strconv.FormatFloat(value, 'f', -1, 64) }
+func formatPercent(value float64) string      { return strconv.FormatFloat(value, 'f', 2, 64) + "%" }
+func formatThroughput(value float64) string   { return strconv.FormatFloat(value, 'f', 2, 64) + " req/s" }
+func formatMilliseconds(value float64) string { return strconv.FormatFloat(value, 'f', 3, 64) + " ms" }
+
+// Delta returns the relative change from base to value in percent, 0 if base is 0
+func Delta(base float64, value float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (value - base) / base * 100
+}
+
+// PrintComparison prints the metrics of every run and their change compared to the first one
+func PrintComparison(runs []Run) {
+	header := []string{"Metric", runs[0].Name}
+	for _, run := range runs[1:] {
+		header = append(header, run.Name, "Δ")
+	}
+	data := pterm.TableData{header}
+
+	base := runs[0].Summary
+	for _, metric := range compareMetrics {
+		row := []string{metric.name, metric.format(metric.value(base))}
+		for _, run := range runs[1:] {
+			value := metric.value(run.Summary)
+			delta := Delta(metric.value(base), value)
+
+			formatted := strconv.FormatFloat(delta, 'f', 1, 64) + "%"
+			if delta > 0 {
+				formatted = "+" + formatted
+			}
+			switch {
+			case delta == 0:
+			case (delta < 0) == metric.lowerIsBetter:
+				formatted = pterm.Green(formatted)
+			default:
+				formatted = pterm.Red(formatted)
+			}
+			row = append(row, metric.format(value), formatted)
+		}
+		data = append(data, row)
+	}
+
+	pterm.Println("\n- Comparison: ")
+	_ = pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+
+	pterm.Println("\n- Mean response time significance (Welch's test): ")
+	for _, run := range runs[1:] {
+		p, significant := Significance(base, run.Summary)
+		outcome := pterm.Yellow("not significant")
+		if significant {
+			outcome = pterm.Green("significant")
+		}
+		pterm.Println("  " + pterm.White(run.Name) + " vs " + pterm.White(runs[0].Name) + ": " + outcome +
+			" (p = " + strconv.FormatFloat(p, 'g', 3, 64) + ", " + significanceStars(p) + ")")
+	}
+}
+
+// compareLatencySeries overlays the response times of the runs that have their samples
+func compareLatencySeries(runs []Run) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{Theme: types.ThemeMacarons}),
+		charts.WithTitleOpts(opts.Title{Title: "Response times"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Right: "0"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "ms"}),
+	)
+
+	var longest int
+	for _, run := range runs {
+		longest = max(longest, len(run.Samples))
+	}
+	requests := make([]string, longest)
+	for i := range requests {
+		requests[i] = "request " + strconv.Itoa(i+1)
+	}
+	line.SetXAxis(requests)
+
+	for _, run := range runs {
+		if len(run.Samples) == 0 {
+			continue
+		}
+		items := make([]opts.LineData, 0, len(run.Samples))
+		for _, sample := range run.Samples {
+			items = append(items, opts.LineData{Value: milliseconds(sample.Timings.Total + sample.Lag)})
+		}
+		line.AddSeries(run.Name, items)
+	}
+	return line
+}
+
+// comparePercentiles overlays the percentile distribution of the runs
+func comparePercentiles(runs []Run) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{Theme: types.ThemeMacarons}),
+		charts.WithTitleOpts(opts.Title{Title: "Percentile distribution"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Right: "0"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "ms"}),
+	)
+
+	labels := make([]string, 0, len(chartPercentiles))
+	for _, p := range chartPercentiles {
+		labels = append(labels, "p"+strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	line.SetXAxis(labels)
+
+	for _, run := range runs {
+		items := make([]opts.LineData, 0, len(chartPercentiles))
+		for _, p := range chartPercentiles {
+			items = append(items, opts.LineData{Value: milliseconds(run.Summary.Histogram.ValueAtPercentile(p))})
+		}
+		line.AddSeries(run.Name, items, charts.WithLineChartOpts(opts.LineChart{Smooth: true, ShowSymbol: true}))
+	}
+	return line
+}
+
+// compareStats groups the main statistics of the runs side by side
+func compareStats(runs []Run) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{Theme: types.ThemeMacarons}),
+		charts.WithTitleOpts(opts.Title{Title: "Response time statistics"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Right: "0"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: "ms"}),
+	)
+
+	stats := []func(Summary) time.Duration{
+		func(s Summary) time.Duration { return s.Mean },
+		func(s Summary) time.Duration { return s.Median },
+		func(s Summary) time.Duration { return s.P90 },
+		func(s Summary) time.Duration { return s.P95 },
+		func(s Summary) time.Duration { return s.P99 },
+	}
+	bar.SetXAxis([]string{"mean", "median", "p90", "p95", "p99"})
+	for _, run := range runs {
+		items := make([]opts.BarData, 0, len(stats))
+		for _, stat := range stats {
+			items = append(items, opts.BarData{Value: milliseconds(stat(run.Summary))})
+		}
+		bar.AddSeries(run.Name, items)
+	}
+	return bar
+}
+
+// CompareRuns loads the runs saved at paths, prints their comparison and charts it,
+// it returns the path of the page
+func CompareRuns(paths []string, options ChartOptions) (string, error) {
+	runs := make([]Run, 0, len(paths))
+	for _, path := range paths {
+		run, err := LoadRun(path)
+		if err != nil {
+			return "", err
+		}
+		runs = append(runs, run)
+	}
+	// Runs saved in dirs with the same name, e.g. before/logs and after/logs, are named after their path
+	for i := range runs {
+		for j := range runs {
+			if i != j && runs[i].Name == runs[j].Name {
+				runs[i].Name = filepath.Clean(paths[i])
+			}
+		}
+	}
+
+	PrintComparison(runs)
+
+	title := options.Title
+	if title == "" {
+		title = DefaultCompareTitle
+	}
+	page := components.NewPage()
+	page.PageTitle = title
+	page.AddCharts(
+		compareLatencySeries(runs),
+		comparePercentiles(runs),
+		compareStats(runs),
+	)
+
+	path := options.chartPath("compare", time.Now())
+	return path, writePage(page, path)
+}
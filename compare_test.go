@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// summaryOf returns the summary of responses whose response times are given in milliseconds
+func summaryOf(respTimes ...float64) Summary {
+	recorder := NewRecorder()
+	for _, respTime := range respTimes {
+		recorder.Record(Response{Results: Results{
+			StatusCode: 200,
+			Timings:    Timings{Total: time.Duration(respTime * float64(time.Millisecond))},
+		}})
+	}
+	return recorder.Summary(time.Second)
+}
+
+func TestSignificance(t *testing.T) {
+	var base, same, slower []float64
+	for i := 0; i < 200; i++ {
+		jitter := float64(i%10) - 4.5
+		base = append(base, 100+jitter)
+		same = append(same, 100.2+jitter)
+		slower = append(slower, 110+jitter)
+	}
+
+	p, significant := Significance(summaryOf(base...), summaryOf(slower...))
+	assert.True(t, significant)
+	assert.Less(t, p, 0.001)
+	assert.Equal(t, "***", significanceStars(p))
+
+	p, significant = Significance(summaryOf(base...), summaryOf(same...))
+	assert.False(t, significant)
+	assert.Equal(t, "ns", significanceStars(p))
+
+	_, significant = Significance(summaryOf(100), summaryOf(200))
+	assert.False(t, significant)
+}
+
+func TestDelta(t *testing.T) {
+	assert.Equal(t, 50.0, Delta(100, 150))
+	assert.Equal(t, -25.0, Delta(100, 75))
+	assert.Equal(t, 0.0, Delta(0, 10))
+}
+
+func TestLoadRun(t *testing.T) {
+	dir := t.TempDir()
+	summary := summaryOf(10, 20, 30)
+
+	summaryPath := filepath.Join(dir, "before.json")
+	assert.NoError(t, WriteSummary(summaryPath, summary))
+	run, err := LoadRun(summaryPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "before", run.Name)
+	assert.Empty(t, run.Samples)
+	assert.Equal(t, summary.Requests, run.Summary.Requests)
+	assert.Equal(t, summary.P95, run.Summary.P95)
+	assert.Equal(t, summary.Histogram, run.Summary.Histogram)
+
+	samples := []Sample{{Index: 1, StartTime: time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC), StatusCode: 200, Timings: Timings{Total: 10 * time.Millisecond}}}
+	_, err = GenCharts(samples, summary, ChartOptions{Out: filepath.Join(dir, "after.html"), JSON: true})
+	assert.NoError(t, err)
+	run, err = LoadRun(filepath.Join(dir, "after.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "after", run.Name)
+	assert.Len(t, run.Samples, 1)
+	assert.Equal(t, summary.Mean, run.Summary.Mean)
+
+	logs := filepath.Join(dir, "logs")
+	assert.NoError(t, os.Mkdir(logs, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(logs, "log1.json"),
+		[]byte(`{"start-time": "2024-03-09 14:05:07 +0000 UTC", "time": "10 ms", "status-code": "200 OK", "response": plain text}`), 0644))
+	run, err = LoadRun(logs)
+	assert.NoError(t, err)
+	assert.Equal(t, "logs", run.Name)
+	assert.Equal(t, 1, run.Summary.Requests)
+
+	out := filepath.Join(dir, "compare.html")
+	path, err := CompareRuns([]string{summaryPath, filepath.Join(dir, "after.json"), logs}, ChartOptions{Out: out})
+	assert.NoError(t, err)
+	assert.Equal(t, out, path)
+	assert.FileExists(t, out)
+}
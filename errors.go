@@ -12,8 +12,33 @@ type PleaseError struct {
 }
 
 var (
-	fewArgsErrMsg       = errors.New("too few args")
-	invalidSyntaxErrMsg = errors.New("the argument is null or has syntax errors")
+	fewArgsErrMsg           = errors.New("too few args")
+	invalidSyntaxErrMsg     = errors.New("the argument is null or has syntax errors")
+	invalidJSONErrMsg       = errors.New("invalid json value")
+	manyBodiesErrMsg        = errors.New("the request body can be given only once")
+	bodyConsumedErrMsg      = errors.New("the request body has already been consumed")
+	fileFieldsErrMsg        = errors.New("file fields can only be sent with --form or --multipart")
+	formJSONErrMsg          = errors.New("raw json fields can't be sent as a form")
+	timeoutErrMsg           = errors.New("request timed out")
+	readTimeoutErrMsg       = errors.New("read timed out")
+	interruptedErrMsg       = errors.New("interrupted")
+	invalidRetryOnErrMsg    = errors.New("invalid retry condition")
+	tooManyRedirectsErrMsg  = errors.New("too many redirects")
+	invalidRateErrMsg       = errors.New("invalid rate")
+	invalidStageErrMsg      = errors.New("invalid stage")
+	failedRequestsErrMsg    = errors.New("failed requests")
+	invalidHistogramErrMsg  = errors.New("invalid histogram")
+	invalidThresholdErrMsg  = errors.New("invalid threshold")
+	thresholdsErrMsg        = errors.New("thresholds failed")
+	noLogRecordsErrMsg      = errors.New("no log records found")
+	invalidLogRecordErrMsg  = errors.New("invalid log record")
+	invalidRedactRuleErrMsg = errors.New("invalid redact rule, expected header:Name, query:name or json:path")
+	invalidHistoryIDErrMsg  = errors.New("no such request in the history")
+	redactedBodyErrMsg      = errors.New("the recorded body has redacted fields, give the body again")
+	redactedURLErrMsg       = errors.New("the recorded url has redacted values, give the url again")
+	invalidBaseURLErrMsg    = errors.New("invalid base url, expected scheme://host[/path]")
+	invalidSpeedErrMsg      = errors.New("invalid speed, expected a positive multiplier")
+	replayMismatchErrMsg    = errors.New("replayed status codes differ from the recording")
 )
 
 func FatalError(err PleaseError) {
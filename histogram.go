@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	// Values below histogramSubBuckets microseconds are recorded exactly,
+	// above it every bucket is less than 1/1024 of its values wide
+	histogramSubBucketBits = 11
+	histogramSubBuckets    = 1 << histogramSubBucketBits
+	histogramHalfBuckets   = histogramSubBuckets / 2
+	// MaxHistogramValue is the highest value recorded, longer response times are recorded as MaxHistogramValue
+	MaxHistogramValue = time.Hour
+)
+
+// Histogram records response times with microsecond resolution in high dynamic range buckets:
+// the values are grouped by power of two and every group is split in histogramHalfBuckets linear buckets,
+// so the memory is bounded by MaxHistogramValue whatever the number of values.
+// A Histogram isn't safe for concurrent use, every worker records its own and Merge adds them up.
+type Histogram struct {
+	counts []int64
+	total  int64
+	// min, max and sum are exact, in microseconds
+	min        int64
+	max        int64
+	sum        float64
+	sumSquares float64
+}
+
+// NewHistogram returns an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// bucketIndex returns the index of the bucket of value, in microseconds
+func bucketIndex(value int64) int {
+	if value < histogramSubBuckets {
+		return int(value)
+	}
+	exponent := bits.Len64(uint64(value)) - histogramSubBucketBits
+	return exponent*histogramHalfBuckets + int(value>>exponent)
+}
+
+// bucketRange returns the lowest and the highest value of the bucket index
+func bucketRange(index int) (int64, int64) {
+	if index < histogramSubBuckets {
+		return int64(index), int64(index)
+	}
+	exponent := index/histogramHalfBuckets - 1
+	lowest := int64(index-exponent*histogramHalfBuckets) << exponent
+	return lowest, lowest + 1<<exponent - 1
+}
+
+// Record adds d to the histogram
+func (histogram *Histogram) Record(d time.Duration) {
+	histogram.RecordValues(min(max(d, 0), MaxHistogramValue).Microseconds(), 1)
+}
+
+// RecordValues adds count times value, in microseconds, to the histogram
+func (histogram *Histogram) RecordValues(value int64, count int64) {
+	if count <= 0 {
+		return
+	}
+
+	index := bucketIndex(value)
+	if index >= len(histogram.counts) {
+		histogram.counts = append(histogram.counts, make([]int64, index+1-len(histogram.counts))...)
+	}
+	histogram.counts[index] += count
+
+	if histogram.total == 0 || value < histogram.min {
+		histogram.min = value
+	}
+	histogram.max = max(histogram.max, value)
+	histogram.total += count
+	histogram.sum += float64(value) * float64(count)
+	histogram.sumSquares += float64(value) * float64(value) * float64(count)
+}
+
+// Merge adds the values of other to the histogram
+func (histogram *Histogram) Merge(other *Histogram) {
+	if other.total == 0 {
+		return
+	}
+
+	if len(other.counts) > len(histogram.counts) {
+		histogram.counts = append(histogram.counts, make([]int64, len(other.counts)-len(histogram.counts))...)
+	}
+	for index, count := range other.counts {
+		histogram.counts[index] += count
+	}
+
+	if histogram.total == 0 || other.min < histogram.min {
+		histogram.min = other.min
+	}
+	histogram.max = max(histogram.max, other.max)
+	histogram.total += other.total
+	histogram.sum += other.sum
+	histogram.sumSquares += other.sumSquares
+}
+
+// Count returns the number of recorded values
+func (histogram *Histogram) Count() int64 {
+	return histogram.total
+}
+
+func (histogram *Histogram) Min() time.Duration {
+	return time.Duration(histogram.min) * time.Microsecond
+}
+
+func (histogram *Histogram) Max() time.Duration {
+	return time.Duration(histogram.max) * time.Microsecond
+}
+
+func (histogram *Histogram) Mean() time.Duration {
+	if histogram.total == 0 {
+		return 0
+	}
+	return time.Duration(histogram.sum / float64(histogram.total) * float64(time.Microsecond))
+}
+
+// StdDev returns the population standard deviation of the recorded values
+func (histogram *Histogram) StdDev() time.Duration {
+	if histogram.total == 0 {
+		return 0
+	}
+	mean := histogram.sum / float64(histogram.total)
+	variance := max(histogram.sumSquares/float64(histogram.total)-mean*mean, 0)
+	return time.Duration(math.Sqrt(variance) * float64(time.Microsecond))
+}
+
+// ValueAtPercentile returns the value below which fall p percent of the recorded values,
+// p goes from 0 to 100
+func (histogram *Histogram) ValueAtPercentile(p float64) time.Duration {
+	if histogram.total == 0 {
+		return 0
+	}
+
+	rank := max(int64(math.Ceil(p/100*float64(histogram.total))), 1)
+	var seen int64
+	for index, count := range histogram.counts {
+		seen += count
+		if seen >= rank {
+			_, highest := bucketRange(index)
+			return time.Duration(min(max(highest, histogram.min), histogram.max)) * time.Microsecond
+		}
+	}
+	return histogram.Max()
+}
+
+// Bucket is a range of values of a histogram
+type Bucket struct {
+	From  time.Duration
+	To    time.Duration
+	Count int64
+}
+
+// Buckets returns the non-empty buckets of the histogram
+func (histogram *Histogram) Buckets() []Bucket {
+	var buckets []Bucket
+	for index, count := range histogram.counts {
+		if count == 0 {
+			continue
+		}
+		lowest, highest := bucketRange(index)
+		buckets = append(buckets, Bucket{
+			From:  time.Duration(lowest) * time.Microsecond,
+			To:    time.Duration(highest) * time.Microsecond,
+			Count: count,
+		})
+	}
+	return buckets
+}
+
+// histogramJSON is the serialised histogram, Counts holds [value in microseconds, count] pairs of the non-empty buckets
+type histogramJSON struct {
+	Unit       string     `json:"unit"`
+	Count      int64      `json:"count"`
+	Min        int64      `json:"min"`
+	Max        int64      `json:"max"`
+	Sum        float64    `json:"sum"`
+	SumSquares float64    `json:"sum-squares"`
+	Counts     [][2]int64 `json:"counts"`
+}
+
+func (histogram *Histogram) MarshalJSON() ([]byte, error) {
+	serialised := histogramJSON{
+		Unit:       "us",
+		Count:      histogram.total,
+		Min:        histogram.min,
+		Max:        histogram.max,
+		Sum:        histogram.sum,
+		SumSquares: histogram.sumSquares,
+		Counts:     [][2]int64{},
+	}
+	for index, count := range histogram.counts {
+		if count > 0 {
+			lowest, _ := bucketRange(index)
+			serialised.Counts = append(serialised.Counts, [2]int64{lowest, count})
+		}
+	}
+	return json.Marshal(serialised)
+}
+
+func (histogram *Histogram) UnmarshalJSON(data []byte) error {
+	var serialised histogramJSON
+	if err := json.Unmarshal(data, &serialised); err != nil {
+		return err
+	}
+
+	*histogram = Histogram{}
+	for _, bucket := range serialised.Counts {
+		if bucket[0] < 0 || bucket[0] > MaxHistogramValue.Microseconds() || bucket[1] < 0 {
+			return invalidHistogramErrMsg
+		}
+		index := bucketIndex(bucket[0])
+		if index >= len(histogram.counts) {
+			histogram.counts = append(histogram.counts, make([]int64, index+1-len(histogram.counts))...)
+		}
+		histogram.counts[index] += bucket[1]
+		histogram.total += bucket[1]
+	}
+	if histogram.total != serialised.Count {
+		return invalidHistogramErrMsg
+	}
+
+	histogram.min = serialised.Min
+	histogram.max = serialised.Max
+	histogram.sum = serialised.Sum
+	histogram.sumSquares = serialised.SumSquares
+	return nil
+}
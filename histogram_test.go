@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketIndex(t *testing.T) {
+	for _, value := range []int64{0, 1, 2047, 2048, 2049, 4095, 4096, 50000, 1234567, MaxHistogramValue.Microseconds()} {
+		lowest, highest := bucketRange(bucketIndex(value))
+		assert.LessOrEqual(t, lowest, value)
+		assert.GreaterOrEqual(t, highest, value)
+		assert.LessOrEqual(t, float64(highest-lowest), float64(value)/1024, value)
+	}
+
+	// Buckets are contiguous
+	for index := 1; index < 10*histogramSubBuckets; index++ {
+		_, previous := bucketRange(index - 1)
+		lowest, _ := bucketRange(index)
+		assert.Equal(t, previous+1, lowest, index)
+	}
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	histogram := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		histogram.Record(time.Duration(i) * time.Microsecond)
+	}
+
+	assert.Equal(t, int64(1000), histogram.Count())
+	assert.Equal(t, time.Microsecond, histogram.Min())
+	assert.Equal(t, time.Millisecond, histogram.Max())
+	assert.Equal(t, 500500*time.Nanosecond, histogram.Mean())
+	assert.Equal(t, 500*time.Microsecond, histogram.ValueAtPercentile(50))
+	assert.Equal(t, 990*time.Microsecond, histogram.ValueAtPercentile(99))
+	assert.Equal(t, time.Millisecond, histogram.ValueAtPercentile(100))
+	assert.Equal(t, time.Microsecond, histogram.ValueAtPercentile(0))
+	assert.Len(t, histogram.Buckets(), 1000)
+}
+
+func TestHistogramBounds(t *testing.T) {
+	histogram := NewHistogram()
+	histogram.Record(-time.Second)
+	histogram.Record(2 * MaxHistogramValue)
+
+	assert.Equal(t, time.Duration(0), histogram.Min())
+	assert.Equal(t, MaxHistogramValue, histogram.Max())
+	assert.Equal(t, time.Duration(0), NewHistogram().ValueAtPercentile(50))
+}
+
+func TestHistogramMerge(t *testing.T) {
+	fast, slow, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := 1; i <= 100; i++ {
+		fast.Record(time.Duration(i) * time.Millisecond)
+		slow.Record(time.Duration(i) * time.Second)
+		all.Record(time.Duration(i) * time.Millisecond)
+		all.Record(time.Duration(i) * time.Second)
+	}
+
+	merged := NewHistogram()
+	merged.Merge(slow)
+	merged.Merge(fast)
+	merged.Merge(NewHistogram())
+
+	assert.Equal(t, all, merged)
+	assert.Equal(t, time.Millisecond, merged.Min())
+	assert.Equal(t, 100*time.Second, merged.Max())
+}
+
+func TestHistogramJSON(t *testing.T) {
+	histogram := NewHistogram()
+	for i := 1; i <= 100; i++ {
+		histogram.Record(time.Duration(i*i) * time.Millisecond)
+	}
+
+	data, err := json.Marshal(histogram)
+	assert.NoError(t, err)
+
+	decoded := NewHistogram()
+	assert.NoError(t, json.Unmarshal(data, decoded))
+	assert.Equal(t, histogram, decoded)
+	assert.Equal(t, histogram.ValueAtPercentile(95), decoded.ValueAtPercentile(95))
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"count": 2, "counts": [[10, 1]]}`), decoded), invalidHistogramErrMsg)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"count": 1, "counts": [[-10, 1]]}`), decoded), invalidHistogramErrMsg)
+}
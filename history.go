@@ -0,0 +1,538 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+	"github.com/pterm/pterm"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// DefaultHistoryMaxSize is the size in MB above which the history is rotated
+	DefaultHistoryMaxSize = 10
+	// HistoryBackups is the number of rotated history files kept, e.g. history.jsonl.1 to history.jsonl.3
+	HistoryBackups = 3
+	// MaxHistoryBody is the size above which a request body isn't recorded
+	MaxHistoryBody = 1 << 20
+	// DefaultHistoryLimit is the number of requests listed by the history command
+	DefaultHistoryLimit = 20
+)
+
+// HistoryEntry is the record of a request in the history
+type HistoryEntry struct {
+	// ID is the number of the request in the history, from 1, it stays the same when the history is rotated
+	ID             int         `json:"id,omitempty"`
+	StartTime      time.Time   `json:"start-time"`
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHeaders http.Header `json:"request-headers"`
+	// RequestBody is encoded as LogEntry.Response, it's missing if it was too large or streamed
+	RequestBody         json.RawMessage `json:"request-body,omitempty"`
+	RequestBodyEncoding string          `json:"request-body-encoding,omitempty"`
+	Time                string          `json:"time"`
+	DurationMs          float64         `json:"duration-ms"`
+	Protocol            string          `json:"protocol,omitempty"`
+	Status              string          `json:"status,omitempty"`
+	StatusCode          int             `json:"status-code"`
+	Headers             http.Header     `json:"headers,omitempty"`
+	ResponseSize        int             `json:"response-size"`
+	Timings             *Timings        `json:"timings,omitempty"`
+	Error               string          `json:"error,omitempty"`
+}
+
+// NewHistoryEntry returns the history record of a request sent as spec, with its secrets hidden by redactor
+func NewHistoryEntry(spec RequestSpec, response Response, redactor *Redactor) HistoryEntry {
+	return NewHistoryEntries(spec, redactor)(response)
+}
+
+// NewHistoryEntries returns a function that returns the history records of the requests of a run sent as spec.
+// The request is redacted and its body read again only once, when the first record is built after it was sent.
+func NewHistoryEntries(spec RequestSpec, redactor *Redactor) func(Response) HistoryEntry {
+	var once sync.Once
+	var request HistoryEntry
+
+	return func(response Response) HistoryEntry {
+		once.Do(func() {
+			// The headers are the ones of the request sent first, the redirects drop some of them with the body
+			request = HistoryEntry{
+				Method:         spec.Method,
+				URL:            redactor.URL(spec.URL),
+				RequestHeaders: redactor.Headers(spec.Headers),
+			}
+			if body, ok := historyBody(spec); ok {
+				request.RequestBody, request.RequestBodyEncoding = encodeBody(redactor.Body(body))
+			}
+		})
+		return newHistoryEntry(spec, request, response, redactor)
+	}
+}
+
+// newHistoryEntry returns the record of response to request, already redacted
+func newHistoryEntry(spec RequestSpec, request HistoryEntry, response Response, redactor *Redactor) HistoryEntry {
+	results := redactor.Results(response.Results)
+	entry := request
+	entry.StartTime = results.StartTime
+	if entry.StartTime.IsZero() {
+		entry.StartTime = time.Now()
+	}
+
+	if response.Err != nil {
+		entry.Error = redactor.Error(response.Err).Error()
+		return entry
+	}
+
+	// The url is the requested one, not the one of the last redirect, so that the request can be sent again
+	logEntry := NewLogEntry(results.URL, spec.Method, results)
+	entry.Time = logEntry.Time
+	entry.DurationMs = logEntry.DurationMs
+	entry.Protocol = results.Protocol
+	entry.Status = results.Status
+	entry.StatusCode = results.StatusCode
+	entry.Headers = results.Headers
+	entry.ResponseSize = logEntry.ResponseSize
+	entry.Timings = logEntry.Timings
+	return entry
+}
+
+// historyBody returns the body of spec if it can be read again and isn't larger than MaxHistoryBody
+func historyBody(spec RequestSpec) (string, bool) {
+	if spec.Body == nil || spec.ContentLength > MaxHistoryBody {
+		return "", false
+	}
+
+	// A body read from stdin can't be read again once sent
+	body, err := spec.Body()
+	if err != nil {
+		return "", false
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, MaxHistoryBody+1))
+	if err != nil || len(data) > MaxHistoryBody {
+		return "", false
+	}
+	return string(data), true
+}
+
+// History is an append-only JSON Lines file with a record per request.
+// It's rotated when it grows above MaxSize and can be written by concurrent goroutines and processes.
+type History struct {
+	Path string
+	// MaxSize is the size in bytes above which the history is rotated, never if 0
+	MaxSize int64
+
+	mu sync.Mutex
+	// dirCreated is set once the dir of the history exists, so that it isn't created for every request
+	dirCreated bool
+}
+
+// NewHistory returns the history written to path, rotated above maxSize MB
+func NewHistory(path string, maxSize int) *History {
+	return &History{Path: path, MaxSize: int64(maxSize) << 20}
+}
+
+// DefaultHistoryPath returns history.jsonl in the please dir of the user config dir, empty if there is none
+func DefaultHistoryPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "please", "history.jsonl")
+}
+
+// Append writes entry at the end of the history, numbered after the last request
+func (history *History) Append(entry HistoryEntry) error {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	if !history.dirCreated {
+		if err := os.MkdirAll(filepath.Dir(history.Path), 0755); err != nil {
+			return err
+		}
+		history.dirCreated = true
+	}
+
+	// Other processes are kept out with a lock file, as the history itself is renamed by the rotation
+	unlock, err := lockFile(history.Path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	lastID, err := history.lastID()
+	if err != nil {
+		return err
+	}
+	entry.ID = lastID + 1
+	record, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	record = append(record, '\n')
+
+	if err := history.rotate(int64(len(record))); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(history.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(record); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// lastID returns the id of the last request of the history, 0 if it's empty.
+// The requests recorded without id are numbered by their position, as in ReadHistory.
+func (history *History) lastID() (int, error) {
+	for i := 0; i <= HistoryBackups; i++ {
+		file := history.Path
+		if i > 0 {
+			file += "." + strconv.Itoa(i)
+		}
+		record, err := lastLine(file)
+		if err != nil {
+			return 0, err
+		}
+		if record == "" {
+			continue
+		}
+		if id := gjson.Get(record, "id").Int(); id > 0 {
+			return int(id), nil
+		}
+
+		entries, err := ReadHistory(history.Path)
+		if err != nil {
+			return 0, err
+		}
+		return len(entries), nil
+	}
+	return 0, nil
+}
+
+// lastLine returns the last line of the file at path that isn't blank, empty if there is none.
+// The file is read from its end, so that the history isn't read whole for every request.
+func lastLine(path string) (string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	var tail []byte
+	chunk := make([]byte, 64*1024)
+	for offset := info.Size(); offset > 0; {
+		n := min(int64(len(chunk)), offset)
+		offset -= n
+		if _, err := file.ReadAt(chunk[:n], offset); err != nil {
+			return "", err
+		}
+		tail = append(chunk[:n:n], tail...)
+
+		trimmed := bytes.TrimRightFunc(tail, unicode.IsSpace)
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return string(trimmed[i+1:]), nil
+		}
+	}
+	return string(bytes.TrimSpace(tail)), nil
+}
+
+// rotate renames the history to history.jsonl.1, and the previous backups to the next number,
+// if writing size more bytes would grow it above MaxSize
+func (history *History) rotate(size int64) error {
+	if history.MaxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(history.Path)
+	if errors.Is(err, os.ErrNotExist) || err == nil && (info.Size() == 0 || info.Size()+size <= history.MaxSize) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := HistoryBackups - 1; i >= 1; i-- {
+		err := os.Rename(history.Path+"."+strconv.Itoa(i), history.Path+"."+strconv.Itoa(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(history.Path, history.Path+".1")
+}
+
+// ReadHistory reads the requests of the history at path, rotated files included, from the oldest one.
+// A missing history has no requests.
+func ReadHistory(path string) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	for i := HistoryBackups; i >= 0; i-- {
+		file := path
+		if i > 0 {
+			file += "." + strconv.Itoa(i)
+		}
+		fileEntries, err := readHistoryFile(file)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	// The requests recorded without id are numbered back from the next one, or by their position
+	next := len(entries) + 1
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].ID == 0 {
+			entries[i].ID = next - 1
+		}
+		next = entries[i].ID
+	}
+	return entries, nil
+}
+
+func readHistoryFile(path string) ([]HistoryEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// FindHistoryEntry returns the request of the history with id
+func FindHistoryEntry(entries []HistoryEntry, id string) (HistoryEntry, error) {
+	n, err := strconv.Atoi(id)
+	if err == nil {
+		for _, entry := range entries {
+			if entry.ID == n {
+				return entry, nil
+			}
+		}
+	}
+	return HistoryEntry{}, fmt.Errorf("%w: %s", invalidHistoryIDErrMsg, id)
+}
+
+// SearchHistory returns the requests whose method, url and status fuzzy match query, the best matches first
+func SearchHistory(entries []HistoryEntry, query string) []HistoryEntry {
+	targets := make([]string, len(entries))
+	for i, entry := range entries {
+		targets[i] = entry.Method + " " + entry.URL + " " + entry.statusText()
+	}
+
+	ranks := fuzzy.RankFindNormalizedFold(query, targets)
+	// Among equal matches the most recent requests come first
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].Distance != ranks[j].Distance {
+			return ranks[i].Distance < ranks[j].Distance
+		}
+		return ranks[i].OriginalIndex > ranks[j].OriginalIndex
+	})
+
+	found := make([]HistoryEntry, 0, len(ranks))
+	for _, rank := range ranks {
+		found = append(found, entries[rank.OriginalIndex])
+	}
+	return found
+}
+
+// statusText returns the status of the response, or the error of a failed request
+func (entry HistoryEntry) statusText() string {
+	if entry.Error != "" {
+		return "error"
+	}
+	return entry.Status
+}
+
+// PrintHistory prints the requests as a table
+func PrintHistory(entries []HistoryEntry) {
+	data := pterm.TableData{{"#", "Date", "Method", "URL", "Status", "Time"}}
+	for _, entry := range entries {
+		duration := ""
+		if entry.Error == "" {
+			duration = FormatDuration(time.Duration(entry.DurationMs * float64(time.Millisecond)))
+		}
+		data = append(data, []string{
+			strconv.Itoa(entry.ID),
+			entry.StartTime.Local().Format(time.DateTime),
+			entry.Method,
+			entry.URL,
+			entry.statusText(),
+			duration,
+		})
+	}
+	_ = pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
+
+// PrintHistoryEntry prints every field of a request of the history
+func PrintHistoryEntry(entry HistoryEntry) {
+	pterm.Println("\n- Request " + pterm.White(entry.ID) + ": " + pterm.Cyan(entry.Method) + " " + pterm.LightBlue(entry.URL))
+	pterm.Println("- Start time: " + pterm.LightBlue(entry.StartTime))
+
+	pterm.Println("\n- Request headers: ")
+	PrintHeaders(entry.RequestHeaders)
+	if body, err := decodeBody(entry.RequestBody, entry.RequestBodyEncoding); err == nil && len(body) > 0 {
+		fmt.Printf("\n- Request body:\n%s\n", body)
+	}
+
+	if entry.Error != "" {
+		pterm.Println("\n- Error: " + pterm.Red(entry.Error))
+		return
+	}
+
+	pterm.Println("\n- Protocol: " + pterm.Blue(entry.Protocol))
+	pterm.Println("- Status: " + pterm.Green(entry.Status))
+	pterm.Println("- Time: " + pterm.Green(entry.Time))
+	if entry.Timings != nil {
+		pterm.Println("  DNS lookup: " + pterm.Green(FormatDuration(entry.Timings.DNSLookup)))
+		pterm.Println("  TCP connect: " + pterm.Green(FormatDuration(entry.Timings.TCPConnect)))
+		pterm.Println("  TLS handshake: " + pterm.Green(FormatDuration(entry.Timings.TLSHandshake)))
+		pterm.Println("  Time to first byte: " + pterm.Green(FormatDuration(entry.Timings.TTFB)))
+		pterm.Println("  Content transfer: " + pterm.Green(FormatDuration(entry.Timings.Transfer)))
+		pterm.Println("  Total: " + pterm.Green(FormatDuration(entry.Timings.Total)))
+	}
+	pterm.Println("- Response size: " + pterm.Green(entry.ResponseSize) + pterm.Green(" bytes"))
+
+	pterm.Println("\n- Headers: ")
+	PrintHeaders(entry.Headers)
+}
+
+// RedactedHeaders returns the names of the request headers recorded without their value
+func (entry HistoryEntry) RedactedHeaders() []string {
+	return RedactedHeaders([]HistoryEntry{entry})
+}
+
+// RedactedHeaders returns the names of the request headers recorded without their value in any of entries
+func RedactedHeaders(entries []HistoryEntry) []string {
+	var names []string
+	for _, entry := range entries {
+		for name, values := range entry.RequestHeaders {
+			if slices.Contains(values, Redacted) && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RedactedFields returns the paths of the fields of the JSON request body recorded without their value
+func (entry HistoryEntry) RedactedFields() []string {
+	var fields []string
+	if entry.RequestBodyEncoding == "" && gjson.ValidBytes(entry.RequestBody) {
+		collectRedactedFields(gjson.ParseBytes(entry.RequestBody), "", &fields)
+	}
+	return fields
+}
+
+// collectRedactedFields adds the paths of the redacted values below value, at path, to fields
+func collectRedactedFields(value gjson.Result, path string, fields *[]string) {
+	value.ForEach(func(key, child gjson.Result) bool {
+		childPath := key.String()
+		if path != "" {
+			childPath = path + "." + childPath
+		}
+		if child.Type == gjson.String && child.Str == Redacted {
+			*fields = append(*fields, childPath)
+		} else if child.IsObject() || child.IsArray() {
+			collectRedactedFields(child, childPath, fields)
+		}
+		return true
+	})
+}
+
+// Spec returns the spec of the recorded request, to send it again.
+// The request items and rawBody override the recorded headers and body as in NewRequestSpec,
+// the redacted headers can't be sent again and a url or a body with redacted values must be given again.
+func (entry HistoryEntry) Spec(args []string, mode BodyMode, rawBody *RawBody) (RequestSpec, error) {
+	items, err := ParseItems(args)
+	if err != nil {
+		return RequestSpec{}, err
+	}
+	if strings.Contains(entry.URL, Redacted) {
+		return RequestSpec{}, fmt.Errorf("%w: %s", redactedURLErrMsg, entry.URL)
+	}
+
+	bodyOverridden := rawBody != nil || len(items.Data) > 0 || len(items.Files) > 0
+	if !bodyOverridden && len(entry.RequestBody) > 0 {
+		// Sending the placeholder instead of the secret would change the request
+		if fields := entry.RedactedFields(); len(fields) > 0 {
+			return RequestSpec{}, fmt.Errorf("%w: %s", redactedBodyErrMsg, strings.Join(fields, ", "))
+		}
+		body, err := decodeBody(entry.RequestBody, entry.RequestBodyEncoding)
+		if err != nil {
+			return RequestSpec{}, err
+		}
+		rawBody = &RawBody{
+			Body:          BytesBody(body),
+			ContentLength: int64(len(body)),
+			ContentType:   entry.RequestHeaders.Get("Content-Type"),
+		}
+		// Older records hold the headers of the last redirect, which may have lost the Content-Type
+		if rawBody.ContentType == "" {
+			rawBody.ContentType = ContentType
+		}
+	}
+
+	spec, err := NewRequestSpec(entry.Method, entry.URL, args, mode, rawBody)
+	if err != nil {
+		return RequestSpec{}, err
+	}
+
+	// The recorded headers replace the default ones, but not the ones given again
+	redacted := entry.RedactedHeaders()
+	for name, values := range entry.RequestHeaders {
+		_, given := items.Headers[name]
+		switch {
+		case given:
+		case name == "Content-Type" && (bodyOverridden || spec.Body != nil && len(values) > 0 && values[0] == ""):
+		case slices.Contains(redacted, name):
+		default:
+			spec.Headers[name] = values
+		}
+	}
+	for _, name := range items.RemovedHeaders {
+		spec.Headers.Del(name)
+	}
+	return spec, nil
+}
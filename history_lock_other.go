@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// lockFile doesn't lock other processes out where flock isn't available,
+// the records are still written with a single append
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
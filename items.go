@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// SepHeader separates a header name from its value, e.g. X-Request-Id:42
+	SepHeader = ":"
+	// SepQuery separates a query parameter from its value, e.g. page==2
+	SepQuery = "=="
+	// SepJSONFile embeds the JSON content of a file in the body, e.g. config:=@config.json
+	SepJSONFile = ":=@"
+	// SepJSON separates a body field from its raw JSON value, e.g. count:=42
+	SepJSON = ":="
+	// SepDataFile embeds the content of a file in the body as a string, e.g. text=@notes.txt
+	SepDataFile = "=@"
+	// SepData separates a body field from its value, e.g. foo=bar
+	SepData = "="
+	// SepFile uploads a file in a multipart body, e.g. avatar@me.png;type=image/png
+	SepFile = "@"
+)
+
+// separators is ordered so that, at the same position, longer separators win
+var separators = []string{SepJSONFile, SepJSON, SepQuery, SepDataFile, SepHeader, SepData, SepFile}
+
+// Items holds the request items that follow the url on the command line
+type Items struct {
+	Headers        http.Header
+	RemovedHeaders []string
+	Query          url.Values
+	Data           []Field
+	Files          []FileField
+}
+
+// FileField is a file uploaded in a multipart body
+type FileField struct {
+	Key  string
+	Path string
+	// Filename sent to the server, the base name of Path if empty
+	Filename string
+	// ContentType of the file, guessed from its extension if empty
+	ContentType string
+}
+
+// ParseItems sorts the request items by their separator.
+// Empty items are ignored.
+func ParseItems(args []string) (Items, error) {
+	items := Items{
+		Headers: make(http.Header),
+		Query:   make(url.Values),
+	}
+
+	for _, arg := range args {
+		if arg == "" {
+			continue
+		}
+
+		key, sep, value, found := splitItem(arg)
+		if !found || key == "" {
+			return Items{}, invalidSyntaxErrMsg
+		}
+
+		switch sep {
+		case SepHeader:
+			if value == "" {
+				items.RemovedHeaders = append(items.RemovedHeaders, key)
+			} else {
+				items.Headers.Add(key, value)
+			}
+		case SepQuery:
+			items.Query.Add(key, value)
+		case SepData, SepDataFile, SepJSON, SepJSONFile:
+			items.Data = append(items.Data, Field{Key: key, Sep: sep, Value: value})
+		case SepFile:
+			file, err := parseFileField(key, value)
+			if err != nil {
+				return Items{}, err
+			}
+			items.Files = append(items.Files, file)
+		}
+	}
+
+	return items, nil
+}
+
+// parseFileField parses a path followed by the optional ;type= and ;filename= parameters
+func parseFileField(key string, value string) (FileField, error) {
+	params := strings.Split(value, ";")
+	file := FileField{Key: key, Path: params[0]}
+	if file.Path == "" {
+		return FileField{}, invalidSyntaxErrMsg
+	}
+
+	for _, param := range params[1:] {
+		name, paramValue, _ := strings.Cut(param, "=")
+		switch strings.TrimSpace(name) {
+		case "type":
+			file.ContentType = paramValue
+		case "filename":
+			file.Filename = paramValue
+		default:
+			return FileField{}, invalidSyntaxErrMsg
+		}
+	}
+
+	return file, nil
+}
+
+// splitItem splits item around the first separator it contains
+func splitItem(item string) (key string, sep string, value string, found bool) {
+	for i := 0; i < len(item); i++ {
+		for _, sep := range separators {
+			if strings.HasPrefix(item[i:], sep) {
+				return item[:i], sep, item[i+len(sep):], true
+			}
+		}
+	}
+	return "", "", "", false
+}
+
+// AddQuery merges the query parameters into the query string of requestUrl
+func AddQuery(requestUrl string, query url.Values) (string, error) {
+	if len(query) == 0 {
+		return requestUrl, nil
+	}
+
+	u, err := url.Parse(requestUrl)
+	if err != nil {
+		return "", err
+	}
+
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += query.Encode()
+
+	return u.String(), nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseItemsHeaders(t *testing.T) {
+	args := []string{"Authorization:Bearer token", "X-Request-Id:42", "Referer:https://example.com", "Accept:"}
+
+	items, err := ParseItems(args)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer token", items.Headers.Get("Authorization"))
+	assert.Equal(t, "42", items.Headers.Get("X-Request-Id"))
+	assert.Equal(t, "https://example.com", items.Headers.Get("Referer"))
+	assert.Equal(t, []string{"Accept"}, items.RemovedHeaders)
+	assert.Empty(t, items.Data)
+}
+
+func TestParseItemsData(t *testing.T) {
+	args := []string{"foo=bar", "url=https://example.com", ""}
+
+	items, err := ParseItems(args)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Field{
+		{Key: "foo", Sep: SepData, Value: "bar"},
+		{Key: "url", Sep: SepData, Value: "https://example.com"},
+	}, items.Data)
+	assert.Empty(t, items.Headers)
+}
+
+func TestParseItemsSeparators(t *testing.T) {
+	args := []string{"a=b=c", "n:=1", "text=@notes.txt", "config:=@config.json", "X-Eq:a=b", "q==x:y"}
+
+	items, err := ParseItems(args)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Field{
+		{Key: "a", Sep: SepData, Value: "b=c"},
+		{Key: "n", Sep: SepJSON, Value: "1"},
+		{Key: "text", Sep: SepDataFile, Value: "notes.txt"},
+		{Key: "config", Sep: SepJSONFile, Value: "config.json"},
+	}, items.Data)
+	assert.Equal(t, "a=b", items.Headers.Get("X-Eq"))
+	assert.Equal(t, "x:y", items.Query.Get("q"))
+}
+
+func TestParseItemsFiles(t *testing.T) {
+	args := []string{"avatar@me.png", "doc@report.pdf;type=application/x-pdf;filename=q1.pdf", "email=me@example.com"}
+
+	items, err := ParseItems(args)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []FileField{
+		{Key: "avatar", Path: "me.png"},
+		{Key: "doc", Path: "report.pdf", Filename: "q1.pdf", ContentType: "application/x-pdf"},
+	}, items.Files)
+	assert.Equal(t, []Field{{Key: "email", Sep: SepData, Value: "me@example.com"}}, items.Data)
+}
+
+func TestParseItemsInvalidSyntax(t *testing.T) {
+	for _, arg := range []string{"foo", ":value", "=value", "file@", "file@a.txt;size=1"} {
+		_, err := ParseItems([]string{arg})
+
+		assert.ErrorIs(t, err, invalidSyntaxErrMsg, arg)
+	}
+}
+
+func TestNewRequestSpecHeaders(t *testing.T) {
+	spec, err := NewRequestSpec(GET, "https://example.com", []string{"X-Api-Version:2", "User-Agent:"}, JSONMode, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2", spec.Headers.Get("X-Api-Version"))
+	assert.Equal(t, "*/*", spec.Headers.Get("Accept"))
+	assert.NotContains(t, spec.Headers, http.CanonicalHeaderKey("User-Agent"))
+}
+
+func TestParseItemsQuery(t *testing.T) {
+	args := []string{"tag==a", "tag==b", "q==hello world", "filter==a=b"}
+
+	items, err := ParseItems(args)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, items.Query["tag"])
+	assert.Equal(t, "hello world", items.Query.Get("q"))
+	assert.Equal(t, "a=b", items.Query.Get("filter"))
+	assert.Empty(t, items.Data)
+}
+
+func TestAddQuery(t *testing.T) {
+	items, err := ParseItems([]string{"tag==a", "tag==b", "q==x&y"})
+	assert.NoError(t, err)
+
+	requestUrl, err := AddQuery("https://example.com/search?page=2", items.Query)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/search?page=2&q=x%26y&tag=a&tag=b", requestUrl)
+}
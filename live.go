@@ -0,0 +1,197 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+const (
+	// liveWindow is the number of recent responses of the rolling percentiles and of the sparkline
+	liveWindow = 60
+	// liveRefresh is the refresh interval of the dashboard
+	liveRefresh = 200 * time.Millisecond
+	// progressWidth is the number of characters of the progress bar
+	progressWidth = 40
+)
+
+// sparkBars are the bars of a sparkline, from the lowest to the highest value
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// Dashboard shows the progress of a run in the terminal while it's running.
+// Its methods are safe for concurrent use.
+type Dashboard struct {
+	mu      sync.Mutex
+	profile LoadProfile
+	start   time.Time
+	area    *pterm.AreaPrinter
+	stop    chan struct{}
+	done    chan struct{}
+
+	requests    int
+	errors      int
+	statusCodes map[int]int
+	// recent holds the response times of the last liveWindow responses
+	recent []time.Duration
+	// completions holds when the responses of the last second arrived
+	completions []time.Time
+}
+
+// NewDashboard returns the dashboard of a run with profile started at start
+func NewDashboard(profile LoadProfile, start time.Time) *Dashboard {
+	return &Dashboard{
+		profile:     profile,
+		start:       start,
+		statusCodes: make(map[int]int),
+	}
+}
+
+// Start shows the dashboard and refreshes it until Stop
+func (dashboard *Dashboard) Start() {
+	dashboard.area, _ = pterm.DefaultArea.Start(dashboard.Render(time.Now()))
+	dashboard.stop = make(chan struct{})
+	dashboard.done = make(chan struct{})
+
+	go func() {
+		defer close(dashboard.done)
+		ticker := time.NewTicker(liveRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				dashboard.area.Update(dashboard.Render(time.Now()))
+			case <-dashboard.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop refreshes the dashboard a last time and leaves it on the terminal
+func (dashboard *Dashboard) Stop() {
+	close(dashboard.stop)
+	<-dashboard.done
+	dashboard.area.Update(dashboard.Render(time.Now()))
+	_ = dashboard.area.Stop()
+}
+
+// Record adds response, received at now, to the dashboard
+func (dashboard *Dashboard) Record(response Response, now time.Time) {
+	dashboard.mu.Lock()
+	defer dashboard.mu.Unlock()
+
+	dashboard.requests++
+	dashboard.completions = append(dashboard.completions, now)
+	if response.Err != nil {
+		dashboard.errors++
+		return
+	}
+
+	dashboard.statusCodes[response.Results.StatusCode]++
+	dashboard.recent = append(dashboard.recent, response.Results.Timings.Total+response.Results.Lag)
+	if len(dashboard.recent) > liveWindow {
+		dashboard.recent = dashboard.recent[len(dashboard.recent)-liveWindow:]
+	}
+}
+
+// Render returns the content of the dashboard at now
+func (dashboard *Dashboard) Render(now time.Time) string {
+	dashboard.mu.Lock()
+	defer dashboard.mu.Unlock()
+
+	// Keep the completions of the last second to count the current requests per second
+	first := sort.Search(len(dashboard.completions), func(i int) bool {
+		return now.Sub(dashboard.completions[i]) < time.Second
+	})
+	dashboard.completions = dashboard.completions[first:]
+
+	elapsed := now.Sub(dashboard.start)
+	var progress string
+	switch {
+	case dashboard.profile.Repetitions > 0:
+		progress = ProgressBar(float64(dashboard.requests)/float64(dashboard.profile.Repetitions), progressWidth) +
+			" " + strconv.Itoa(dashboard.requests) + "/" + strconv.Itoa(dashboard.profile.Repetitions)
+	case dashboard.profile.Duration > 0:
+		progress = ProgressBar(float64(elapsed)/float64(dashboard.profile.Duration), progressWidth) +
+			" " + strconv.Itoa(dashboard.requests) + " requests"
+	default:
+		progress = strconv.Itoa(dashboard.requests) + " requests"
+	}
+
+	recent := append([]time.Duration(nil), dashboard.recent...)
+	sort.Slice(recent, func(i, j int) bool { return recent[i] < recent[j] })
+
+	var builder strings.Builder
+	builder.WriteString("- Progress: " + pterm.Cyan(progress) + " in " + pterm.Cyan(elapsed.Round(100*time.Millisecond)) + "\n")
+	builder.WriteString("- Current RPS: " + pterm.Green(len(dashboard.completions)) + "\n")
+	builder.WriteString("- Rolling p50/p95: " + pterm.Green(FormatDuration(nearestRank(recent, 50))) + " / " +
+		pterm.Green(FormatDuration(nearestRank(recent, 95))) + "\n")
+	builder.WriteString("- Errors: " + errorCount(dashboard.errors) + "\n")
+	builder.WriteString("- Status codes: " + formatStatusCodes(dashboard.statusCodes) + "\n")
+	builder.WriteString("- Recent latencies: " + pterm.LightBlue(Sparkline(dashboard.recent)))
+	return builder.String()
+}
+
+// nearestRank returns the percentile p of the sorted values, 0 if there are none
+func nearestRank(values []time.Duration, p float64) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	return values[min(max(rank-1, 0), len(values)-1)]
+}
+
+func errorCount(errors int) string {
+	if errors == 0 {
+		return pterm.Green(errors)
+	}
+	return pterm.Red(errors)
+}
+
+// formatStatusCodes formats the count of every status code, sorted by status code
+func formatStatusCodes(statusCodes map[int]int) string {
+	codes := make([]int, 0, len(statusCodes))
+	for statusCode := range statusCodes {
+		codes = append(codes, statusCode)
+	}
+	sort.Ints(codes)
+
+	counts := make([]string, 0, len(codes))
+	for _, statusCode := range codes {
+		counts = append(counts, pterm.White(statusCode)+": "+pterm.Yellow(statusCodes[statusCode]))
+	}
+	return strings.Join(counts, ", ")
+}
+
+// ProgressBar draws a bar of width characters filled for the fraction done
+func ProgressBar(done float64, width int) string {
+	filled := int(min(max(done, 0), 1) * float64(width))
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+// Sparkline draws a bar per value, scaled from the lowest to the highest value
+func Sparkline(values []time.Duration) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	lowest, highest := values[0], values[0]
+	for _, value := range values {
+		lowest, highest = min(lowest, value), max(highest, value)
+	}
+
+	line := make([]rune, 0, len(values))
+	for _, value := range values {
+		bar := 0
+		if highest > lowest {
+			bar = int(float64(value-lowest) / float64(highest-lowest) * float64(len(sparkBars)-1))
+		}
+		line = append(line, sparkBars[bar])
+	}
+	return string(line)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "", Sparkline(nil))
+	assert.Equal(t, "▁▁▁", Sparkline([]time.Duration{5, 5, 5}))
+	assert.Equal(t, "▁▂▃▄▅▆▇█", Sparkline([]time.Duration{0, 1, 2, 3, 4, 5, 6, 7}))
+	assert.Equal(t, "█▁▄", Sparkline([]time.Duration{10 * time.Millisecond, time.Millisecond, 5500 * time.Microsecond}))
+}
+
+func TestProgressBar(t *testing.T) {
+	assert.Equal(t, "[░░░░]", ProgressBar(0, 4))
+	assert.Equal(t, "[██░░]", ProgressBar(0.5, 4))
+	assert.Equal(t, "[████]", ProgressBar(2, 4))
+}
+
+func TestDashboardRender(t *testing.T) {
+	pterm.DisableColor()
+	t.Cleanup(pterm.EnableColor)
+
+	start := time.Now()
+	dashboard := NewDashboard(LoadProfile{Repetitions: 10}, start)
+	for i := 1; i <= 4; i++ {
+		response := Response{Index: i, Results: Results{StatusCode: 200, Timings: Timings{Total: time.Duration(i) * time.Millisecond}}}
+		dashboard.Record(response, start.Add(time.Duration(i)*400*time.Millisecond))
+	}
+	dashboard.Record(Response{Index: 5, Err: errors.New("connection refused")}, start.Add(2*time.Second))
+
+	lines := strings.Split(dashboard.Render(start.Add(2*time.Second)), "\n")
+	assert.Equal(t, []string{
+		"- Progress: [" + strings.Repeat("█", 20) + strings.Repeat("░", 20) + "] 5/10 in 2s",
+		"- Current RPS: 3",
+		"- Rolling p50/p95: 2.000 ms / 4.000 ms",
+		"- Errors: 1",
+		"- Status codes: 200: 4",
+		"- Recent latencies: ▁▃▅█",
+	}, lines)
+}
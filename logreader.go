@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// logTimeLayout is the layout of the start time written by GenLog
+const logTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// LogRecord is a request read back from a log file
+type LogRecord struct {
+	Sample Sample
+	// BodySize is the size of the logged response
+	BodySize int
+}
+
+// ReadLogs reads the records of log files written by GenLog: log.json files, logs dirs
+// and JSON Lines files with a record per line. The records are sorted by start time
+// and renumbered, so that the logs of several runs can be merged.
+func ReadLogs(paths []string) ([]LogRecord, error) {
+	files, err := logFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []LogRecord
+	for _, file := range files {
+		err := scanLogFile(file, func(data string, where string) error {
+			// The history records the failed requests too, they have no response to read
+			if gjson.Get(data, "error").Exists() {
+				return nil
+			}
+			record, err := ParseLogRecord(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", where, err)
+			}
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, noLogRecordsErrMsg
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Sample.StartTime.Before(records[j].Sample.StartTime)
+	})
+	for i := range records {
+		records[i].Sample.Index = i + 1
+	}
+	return records, nil
+}
+
+// logFiles returns the files of paths, with the log files of the dirs in place of the dirs
+func logFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		dirFiles, err := filepath.Glob(filepath.Join(path, "*.json*"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+// scanLogFile calls handle with every record of a log file and where it is, the file or the file and the line.
+// A .jsonl file, or a rotated one such as history.jsonl.1, holds a record per line.
+func scanLogFile(path string, handle func(data string, where string) error) error {
+	if !strings.Contains(filepath.Base(path), ".jsonl") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return handle(string(data), path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		if err := handle(scanner.Text(), path+":"+strconv.Itoa(line)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ParseLogRecord parses a record written by GenLog.
+// The logged response isn't always valid JSON, so the record is read field by field.
+func ParseLogRecord(data string) (LogRecord, error) {
+	startTime, err := parseLogTime(gjson.Get(data, "start-time"))
+	if err != nil {
+		return LogRecord{}, fmt.Errorf("%w: start-time", invalidLogRecordErrMsg)
+	}
+
+	// duration-ms is more precise than the time of the logs written before it
+	respTime, ok := parseLogDuration(gjson.Get(data, "duration-ms"))
+	if !ok {
+		respTime, ok = parseLogDuration(gjson.Get(data, "time"))
+	}
+	if !ok {
+		return LogRecord{}, fmt.Errorf("%w: time", invalidLogRecordErrMsg)
+	}
+
+	statusCode, ok := parseLogStatusCode(gjson.Get(data, "status-code"))
+	if !ok {
+		return LogRecord{}, fmt.Errorf("%w: status-code", invalidLogRecordErrMsg)
+	}
+
+	timings := gjson.Get(data, "timings")
+	duration := func(name string) time.Duration {
+		d, _ := parseLogDuration(timings.Get(name))
+		return d
+	}
+	sample := Sample{
+		StartTime:  startTime,
+		StatusCode: statusCode,
+		RespTime:   respTime.Milliseconds(),
+		Timings: Timings{
+			DNSLookup:    duration("dns-lookup"),
+			TCPConnect:   duration("tcp-connect"),
+			TLSHandshake: duration("tls-handshake"),
+			TTFB:         duration("ttfb"),
+			Transfer:     duration("transfer"),
+			Total:        duration("total"),
+		},
+	}
+	// Logs written before the timings only have the response time
+	if sample.Timings.Total == 0 {
+		sample.Timings.Total = respTime
+	}
+	sample.Lag = max(respTime-sample.Timings.Total, 0)
+
+	// Logs written before the response size only have the response
+	response := gjson.Get(data, "response")
+	bodySize := len(response.Raw)
+	if size := gjson.Get(data, "response-size"); size.Exists() {
+		bodySize = int(size.Int())
+	} else if response.Type == gjson.String {
+		bodySize = len(response.String())
+	}
+
+	return LogRecord{Sample: sample, BodySize: bodySize}, nil
+}
+
+// parseLogTime parses a start time, either in the GenLog layout or in RFC 3339
+func parseLogTime(value gjson.Result) (time.Time, error) {
+	startTime, err := time.Parse(logTimeLayout, value.String())
+	if err != nil {
+		return time.Parse(time.RFC3339Nano, value.String())
+	}
+	return startTime, nil
+}
+
+// parseLogDuration parses a duration logged either as a number of milliseconds or as a "12.345 ms" string
+func parseLogDuration(value gjson.Result) (time.Duration, bool) {
+	switch value.Type {
+	case gjson.Number:
+		return time.Duration(value.Float() * float64(time.Millisecond)), true
+	case gjson.String:
+		milliseconds, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value.String(), "ms")), 64)
+		return time.Duration(milliseconds * float64(time.Millisecond)), err == nil
+	}
+	return 0, false
+}
+
+// parseLogStatusCode parses a status code logged either as a number or as a "200 OK" status
+func parseLogStatusCode(value gjson.Result) (int, bool) {
+	if value.Type == gjson.Number {
+		return int(value.Int()), true
+	}
+	code, _, _ := strings.Cut(value.String(), " ")
+	statusCode, err := strconv.Atoi(code)
+	return statusCode, err == nil
+}
+
+// ChartLogs writes the charts of the requests logged in paths
+func ChartLogs(paths []string, options ChartOptions) (string, error) {
+	records, err := ReadLogs(paths)
+	if err != nil {
+		return "", err
+	}
+
+	samples, summary := summarizeRecords(records)
+	PrintSummary(summary, 0)
+	return GenCharts(samples, summary, options)
+}
+
+// summarizeRecords returns the samples and the summary of logged requests
+func summarizeRecords(records []LogRecord) ([]Sample, Summary) {
+	recorder := NewRecorder()
+	samples := make([]Sample, 0, len(records))
+	start, end := records[0].Sample.StartTime, records[0].Sample.StartTime
+	for _, record := range records {
+		sample := record.Sample
+		samples = append(samples, sample)
+		recorder.RecordSample(sample, int64(record.BodySize))
+		if done := sample.StartTime.Add(sample.Timings.Total); done.After(end) {
+			end = done
+		}
+	}
+	return samples, recorder.Summary(end.Sub(start))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogRecord(t *testing.T) {
+	// The response isn't JSON, as GenLog writes it when the body is plain text
+	record, err := ParseLogRecord(`{
+  "url": "https://example.com",
+  "start-time": "2024-03-09 14:05:07.5 +0000 UTC",
+  "time": "12 ms",
+  "request-type": "GET",
+  "status-code": "503 Service Unavailable",
+  "timings": {"dns-lookup":"1.000 ms","tcp-connect":"2.000 ms","tls-handshake":"0.000 ms","ttfb":"10.000 ms","transfer":"0.250 ms","total":"10.250 ms"},
+  "response": upstream down
+}`)
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2024, 3, 9, 14, 5, 7, 500000000, time.UTC).Equal(record.Sample.StartTime))
+	assert.Equal(t, 503, record.Sample.StatusCode)
+	assert.Equal(t, int64(12), record.Sample.RespTime)
+	assert.Equal(t, time.Millisecond, record.Sample.Timings.DNSLookup)
+	assert.Equal(t, 10250*time.Microsecond, record.Sample.Timings.Total)
+	assert.Equal(t, 1750*time.Microsecond, record.Sample.Lag)
+
+	// Records without timings and with numeric fields
+	record, err = ParseLogRecord(`{"start-time": "2024-03-09T14:05:07Z", "time": 7.5, "status-code": 200, "response": "ok"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, record.Sample.StatusCode)
+	assert.Equal(t, 7500*time.Microsecond, record.Sample.Timings.Total)
+	assert.Equal(t, 2, record.BodySize)
+
+	for _, data := range []string{
+		`{"time": "1 ms", "status-code": "200 OK"}`,
+		`{"start-time": "2024-03-09T14:05:07Z", "status-code": "200 OK"}`,
+		`{"start-time": "2024-03-09T14:05:07Z", "time": "1 ms", "status-code": "OK"}`,
+	} {
+		_, err := ParseLogRecord(data)
+		assert.ErrorIs(t, err, invalidLogRecordErrMsg, data)
+	}
+}
+
+func TestReadLogs(t *testing.T) {
+	dir := t.TempDir()
+	logs := filepath.Join(dir, "logs")
+	assert.NoError(t, os.Mkdir(logs, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(logs, "log1.json"),
+		[]byte(`{"start-time": "2024-03-09 14:05:09 +0000 UTC", "time": "30 ms", "status-code": "200 OK", "response": {"a": 1}}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(logs, "log2.json"),
+		[]byte(`{"start-time": "2024-03-09 14:05:07 +0000 UTC", "time": "10 ms", "status-code": "200 OK", "response": ""}`), 0644))
+	history := filepath.Join(dir, "history.jsonl")
+	assert.NoError(t, os.WriteFile(history, []byte(
+		`{"start-time": "2024-03-09T14:05:08Z", "time": 20, "status-code": 500, "response": "boom"}`+"\n\n"+
+			`{"start-time": "2024-03-09T14:05:08Z", "method": "GET", "status-code": 0, "error": "connection refused"}`+"\n"), 0644))
+
+	records, err := ReadLogs([]string{logs, history})
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	for i, record := range records {
+		assert.Equal(t, i+1, record.Sample.Index)
+		assert.Equal(t, int64(10*(i+1)), record.Sample.RespTime)
+	}
+
+	_, err = ReadLogs([]string{t.TempDir()})
+	assert.ErrorIs(t, err, noLogRecordsErrMsg)
+	_, err = ReadLogs([]string{filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+
+	out := filepath.Join(dir, "chart.html")
+	path, err := ChartLogs([]string{logs, history}, ChartOptions{Out: out})
+	assert.NoError(t, err)
+	assert.Equal(t, out, path)
+	assert.FileExists(t, out)
+}
@@ -1,31 +1,121 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-
-	"github.com/tidwall/sjson"
+	"time"
+	"unicode/utf8"
 )
 
+// LogEntry is the record of a request written by GenLog
+type LogEntry struct {
+	URL         string      `json:"url"`
+	StartTime   time.Time   `json:"start-time"`
+	Time        string      `json:"time"`
+	DurationMs  float64     `json:"duration-ms"`
+	RequestType string      `json:"request-type"`
+	Protocol    string      `json:"protocol"`
+	Status      string      `json:"status"`
+	StatusCode  int         `json:"status-code"`
+	Timings     *Timings    `json:"timings,omitempty"`
+	Redirects   []Redirect  `json:"redirects,omitempty"`
+	Attempts    []Attempt   `json:"attempts,omitempty"`
+	Headers     http.Header `json:"headers"`
+	// Response is the body as JSON if it's valid JSON, otherwise a string, base64 encoded if it's binary
+	Response         json.RawMessage `json:"response"`
+	ResponseEncoding string          `json:"response-encoding,omitempty"`
+	// ResponseSize is the size of the body in bytes
+	ResponseSize int `json:"response-size"`
+}
+
+// NewLogEntry returns the log record of a request
+func NewLogEntry(requestUrl string, requestType string, results Results) LogEntry {
+	duration := results.Timings.Total + results.Lag
+	if duration == 0 {
+		duration = time.Duration(results.RespTime) * time.Millisecond
+	}
+
+	entry := LogEntry{
+		URL:         requestUrl,
+		StartTime:   results.StartTime,
+		Time:        strconv.FormatInt(results.RespTime, 10) + " ms",
+		DurationMs:  milliseconds(duration),
+		RequestType: requestType,
+		Protocol:    results.Protocol,
+		Status:      results.Status,
+		StatusCode:  results.StatusCode,
+		Redirects:   results.Redirects,
+		Headers:     results.Headers,
+	}
+	// Record the time spent in every phase of the request
+	if results.Timings.Total > 0 {
+		timings := results.Timings
+		entry.Timings = &timings
+	}
+	// Record the attempts of a retried request
+	if len(results.Attempts) > 1 {
+		entry.Attempts = results.Attempts
+	}
+	entry.Response, entry.ResponseEncoding = encodeBody(results.StrBody)
+	entry.ResponseSize = len(results.StrBody)
+
+	return entry
+}
+
+// encodeBody returns body as JSON: itself if it's valid JSON, a string if it's text,
+// a base64 string with the "base64" encoding otherwise
+func encodeBody(body string) (json.RawMessage, string) {
+	trimmed := strings.TrimSpace(body)
+	if trimmed != "" && json.Valid([]byte(trimmed)) {
+		var compacted bytes.Buffer
+		if json.Compact(&compacted, []byte(trimmed)) == nil {
+			return compacted.Bytes(), ""
+		}
+	}
+
+	if utf8.ValidString(body) {
+		encoded, _ := json.Marshal(body)
+		return encoded, ""
+	}
+
+	encoded, _ := json.Marshal(base64.StdEncoding.EncodeToString([]byte(body)))
+	return encoded, "base64"
+}
+
+// decodeBody returns the body encoded by encodeBody.
+// A JSON body that is a single string is read back as text, the only difference being its quotes.
+func decodeBody(encoded json.RawMessage, encoding string) ([]byte, error) {
+	if len(encoded) == 0 || encoded[0] != '"' {
+		return encoded, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(encoded, &text); err != nil {
+		return nil, err
+	}
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+// GenLog writes the log record of a request to log.json, or to logs/log<i>.json if the request is repeated.
+// It returns the number of bytes written.
 func GenLog(requestUrl string, requestType string, results Results, repetitions int, i int) int {
-	const jsonTemplate = `
-{
-  "url": "",
-  "start-time": "",
-  "time": "",
-  "request-type": "",
-  "status-code": "",
-`
-	value, _ := sjson.Set(jsonTemplate, "url", requestUrl)
-	value, _ = sjson.Set(value, "start-time", results.StartTime.Format("2006-01-02 15:04:05.999999999 -0700 MST"))
-	value, _ = sjson.Set(value, "time", strconv.FormatInt(results.RespTime, 10)+" ms")
-	value, _ = sjson.Set(value, "request-type", requestType)
-	value, _ = sjson.Set(value, "status-code", results.Status)
+	value, err := json.MarshalIndent(NewLogEntry(requestUrl, requestType, results), "", "  ")
+	if err != nil {
+		fmt.Printf("please: log file's error: %v\n", err)
+		return 0
+	}
 
 	// Create the log file/s
 	var filePath string
@@ -47,6 +137,7 @@ func GenLog(requestUrl string, requestType string, results Results, repetitions
 	logFile, err := os.Create(filePath)
 	if err != nil {
 		fmt.Printf("please: log file's error: %v\n", err)
+		return 0
 	}
 	defer func(logFile *os.File) {
 		err := logFile.Close()
@@ -56,13 +147,7 @@ func GenLog(requestUrl string, requestType string, results Results, repetitions
 	}(logFile)
 
 	// Write to the log.json file
-	var logBody string
-	if results.StrBody != "" {
-		logBody = value + "  \"response\": " + results.StrBody + "\n}"
-	} else {
-		logBody = value + "  \"response\": \"\"\n}"
-	}
-	byteQuantity, err := logFile.WriteString(strings.TrimSpace(logBody))
+	byteQuantity, err := logFile.Write(append(value, '\n'))
 	if err != nil {
 		log.Fatalf("please: error writing the log file: %v\n", err)
 	}
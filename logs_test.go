@@ -1,10 +1,15 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"io"
+	"net/http"
 	"os"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 // Generates a JSON log file with the expected format and content
@@ -13,10 +18,13 @@ func TestGenerateLogWithExpectedFormatAndContent(t *testing.T) {
 	requestUrl := "https://example.com"
 	requestType := "GET"
 	results := Results{
-		StartTime: time.Now(),
-		RespTime:  100,
-		Status:    "200 OK",
-		StrBody:   "{\"test\": \"test\"}",
+		StartTime:  time.Now(),
+		RespTime:   100,
+		Protocol:   "HTTP/1.1",
+		Status:     "200 OK",
+		StatusCode: 200,
+		Headers:    http.Header{"Content-Type": {"application/json"}},
+		StrBody:    "{\"test\": \"test\"}",
 	}
 	repetitions := 1
 	i := 0
@@ -45,12 +53,24 @@ func TestGenerateLogWithExpectedFormatAndContent(t *testing.T) {
 	// Assert the log file content
 	expectedLogContent := `{
   "url": "https://example.com",
-  "start-time": "` + results.StartTime.Format("2006-01-02 15:04:05.999999999 -0700 MST") + `",
+  "start-time": "` + results.StartTime.Format(time.RFC3339Nano) + `",
   "time": "100 ms",
+  "duration-ms": 100,
   "request-type": "GET",
-  "status-code": "200 OK",
-  "response": {"test": "test"}
-}`
+  "protocol": "HTTP/1.1",
+  "status": "200 OK",
+  "status-code": 200,
+  "headers": {
+    "Content-Type": [
+      "application/json"
+    ]
+  },
+  "response": {
+    "test": "test"
+  },
+  "response-size": 16
+}
+`
 	if string(logContent) != expectedLogContent {
 		t.Errorf("Unexpected log file content.\nExpected: %s\nActual: %s", expectedLogContent, string(logContent))
 	}
@@ -59,3 +79,62 @@ func TestGenerateLogWithExpectedFormatAndContent(t *testing.T) {
 		t.Errorf("The log file is empty.")
 	}
 }
+
+// Responses that aren't JSON are logged as strings, binary ones in base64
+func TestLogEntryResponseEncoding(t *testing.T) {
+	binary := string([]byte{0x89, 'P', 'N', 'G', 0xff, 0x00})
+
+	for body, expected := range map[string]struct {
+		response string
+		encoding string
+	}{
+		"":                   {`""`, ""},
+		"<html>hi</html>":    {`"\u003chtml\u003ehi\u003c/html\u003e"`, ""},
+		"plain \"text\"\n":   {`"plain \"text\"\n"`, ""},
+		" [1, 2] ":           {`[1,2]`, ""},
+		`{"a": {"b": null}}`: {`{"a":{"b":null}}`, ""},
+		binary:               {`"` + base64.StdEncoding.EncodeToString([]byte(binary)) + `"`, "base64"},
+	} {
+		entry := NewLogEntry("https://example.com", GET, Results{StatusCode: 204, StrBody: body})
+		assert.Equal(t, expected.response, string(entry.Response), body)
+		assert.Equal(t, expected.encoding, entry.ResponseEncoding, body)
+
+		// The body is decoded back, JSON bodies are compacted
+		decoded, err := decodeBody(entry.Response, entry.ResponseEncoding)
+		assert.NoError(t, err)
+		if expected.response[0] == '"' {
+			assert.Equal(t, body, string(decoded))
+		} else {
+			assert.JSONEq(t, body, string(decoded))
+		}
+
+		data, err := json.Marshal(entry)
+		assert.NoError(t, err)
+		assert.True(t, json.Valid(data))
+
+		// The log record can be read back
+		record, err := ParseLogRecord(string(data))
+		assert.NoError(t, err)
+		assert.Equal(t, 204, record.Sample.StatusCode)
+		assert.Equal(t, len(body), record.BodySize, body)
+	}
+}
+
+// The redirects are logged with their numeric status code next to the status text
+func TestLogEntryRedirects(t *testing.T) {
+	results := Results{
+		StatusCode: 200,
+		Redirects: []Redirect{
+			{URL: "https://example.com/old", StatusCode: 301, Status: "301 Moved Permanently", Location: "/new", RespTime: 5},
+		},
+	}
+
+	data, err := json.Marshal(NewLogEntry("https://example.com/old", GET, results))
+	assert.NoError(t, err)
+	var record struct {
+		Redirects json.RawMessage `json:"redirects"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &record))
+	assert.JSONEq(t, `[{"url": "https://example.com/old", "status": "301 Moved Permanently", "status-code": 301, "location": "/new", "time": "5 ms"}]`,
+		string(record.Redirects))
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/pterm/pterm"
@@ -12,13 +19,20 @@ import (
 )
 
 type Results struct {
-	Headers    http.Header
-	Protocol   string
-	RespTime   int64
-	StrBody    string
-	StartTime  time.Time
-	StatusCode int
-	Status     string
+	URL            string
+	RequestHeaders http.Header
+	Headers        http.Header
+	Protocol       string
+	RespTime       int64
+	StrBody        string
+	StartTime      time.Time
+	StatusCode     int
+	Status         string
+	Attempts       []Attempt
+	Redirects      []Redirect
+	Timings        Timings
+	// Lag is how late the request started compared to its schedule, it's included in RespTime
+	Lag time.Duration
 }
 
 const (
@@ -31,10 +45,13 @@ const (
 	OPTIONS string = "OPTIONS"
 
 	GenericError = "please: error: "
+
+	Version = "0.3.1"
 )
 
 func PrintResults(results Results) {
-	pterm.Println("\n- Start time: " + pterm.LightBlue(results.StartTime))
+	pterm.Println("\n- URL: " + pterm.LightBlue(results.URL))
+	pterm.Println("- Start time: " + pterm.LightBlue(results.StartTime))
 	pterm.Println("- Protocol: " + pterm.Blue(results.Protocol))
 
 	outputStringStatus := "- Status: "
@@ -51,74 +68,440 @@ func PrintResults(results Results) {
 	}
 
 	pterm.Println("- Time: " + pterm.Green(results.RespTime) + pterm.Green(" ms"))
-	pterm.Println("\n- Headers: ")
+	pterm.Println("  DNS lookup: " + pterm.Green(FormatDuration(results.Timings.DNSLookup)))
+	pterm.Println("  TCP connect: " + pterm.Green(FormatDuration(results.Timings.TCPConnect)))
+	pterm.Println("  TLS handshake: " + pterm.Green(FormatDuration(results.Timings.TLSHandshake)))
+	pterm.Println("  Time to first byte: " + pterm.Green(FormatDuration(results.Timings.TTFB)))
+	pterm.Println("  Content transfer: " + pterm.Green(FormatDuration(results.Timings.Transfer)))
+	pterm.Println("  Total: " + pterm.Green(FormatDuration(results.Timings.Total)))
 
-	for name, values := range results.Headers {
-		for _, value := range values {
-			pterm.Println("  " + pterm.White(name) + ": " + pterm.LightBlue(value))
+	if len(results.Redirects) > 0 {
+		pterm.Println("- Redirects: ")
+		for _, redirect := range results.Redirects {
+			pterm.Println("  " + pterm.Magenta(redirect.Status) + " " + pterm.LightBlue(redirect.URL) +
+				" -> " + pterm.LightBlue(redirect.Location) + ", " + pterm.Green(redirect.RespTime) + pterm.Green(" ms"))
 		}
 	}
 
+	if len(results.Attempts) > 1 {
+		pterm.Println("- Attempts: ")
+		for _, attempt := range results.Attempts {
+			outcome := strconv.Itoa(attempt.StatusCode)
+			if attempt.Err != nil {
+				outcome = attempt.Err.Error()
+			}
+			pterm.Println("  " + pterm.White(attempt.Number) + ": " + pterm.LightBlue(outcome) +
+				" after " + pterm.Yellow(attempt.Delay) + ", " + pterm.Green(attempt.RespTime) + pterm.Green(" ms"))
+		}
+	}
+	pterm.Println("\n- Request headers: ")
+	PrintHeaders(results.RequestHeaders)
+
+	pterm.Println("\n- Headers: ")
+	PrintHeaders(results.Headers)
+
 	if results.StrBody != "" {
 		fmt.Printf("\n- Response:\n%v\n", results.StrBody)
 	}
 }
 
-func Request(requestType string, requestUrl string, createLog bool, genChart bool, repetitions int, keysValues []string) {
-	var respTimes []int64
+// PrintHeaders prints the headers sorted by name
+func PrintHeaders(headers http.Header) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range headers[name] {
+			if value == "" {
+				continue
+			}
+			pterm.Println("  " + pterm.White(name) + ": " + pterm.LightBlue(value))
+		}
+	}
+}
+
+// RunOptions holds the settings of a run of requests
+type RunOptions struct {
+	CreateLog bool
+	GenChart  bool
+	Chart     ChartOptions
+	Profile   LoadProfile
+	// SummaryJSON is the path of the JSON summary of the run, none if empty
+	SummaryJSON string
+	// Thresholds are checked against the summary, when given they replace the failed requests check
+	Thresholds []Threshold
+	// Live shows a dashboard instead of every response
+	Live bool
+	// History records every request, none if nil
+	History *History
+	// Redactor hides the secrets in the output, the logs and the history
+	Redactor *Redactor
+}
+
+// Request sends the request as described by the load profile.
+// A run of many requests goes on after a failed request and ends with a summary.
+// If ctx is cancelled the run stops, but the log files and the chart of the completed requests are still generated.
+func Request(ctx context.Context, spec RequestSpec, options RunOptions) {
+	var samples []Sample
 	var results Results
-	var err error
+	var completed int
 
 	logFileSuccessfully := "- Log file generated successfully."
 
-	for i := 1; i <= repetitions; i++ {
-		switch requestType {
-		case GET:
-			results, err = GetRequest(requestUrl)
-		case POST:
-			results, err = PostRequest(requestUrl, keysValues)
-		case PUT:
-			results, err = PutRequest(requestUrl, keysValues)
-		case PATCH:
-			results, err = PatchRequest(requestUrl, keysValues)
-		case DELETE:
-			results, err = DeleteRequest(requestUrl)
-		case HEAD:
-			results, err = HeadRequest(requestUrl)
-		case OPTIONS:
-			results, err = OptionsRequest(requestUrl)
+	repetitions := options.Profile.Repetitions
+	recorder := NewRecorder()
+	start := time.Now()
+
+	var dashboard *Dashboard
+	if options.Live {
+		dashboard = NewDashboard(options.Profile, start)
+		dashboard.Start()
+	}
+
+	var historyFailed bool
+	var historyEntry func(Response) HistoryEntry
+	if options.History != nil {
+		historyEntry = NewHistoryEntries(spec, options.Redactor)
+	}
+
+	// The dashboard shows the responses when they arrive, not once the previous ones have been handled
+	var arrived func(Response)
+	if dashboard != nil {
+		arrived = func(response Response) {
+			if response.Err == nil || ctx.Err() == nil {
+				dashboard.Record(response, time.Now())
+			}
 		}
+	}
 
-		if err != nil {
+	RunPool(ctx, spec, options.Profile, arrived, func(response Response) {
+		if response.Err != nil && ctx.Err() != nil {
+			return
+		}
+		// A history that can't be written is reported once and doesn't stop the run
+		if options.History != nil && !historyFailed {
+			if err := options.History.Append(historyEntry(response)); err != nil {
+				historyFailed = true
+				fmt.Printf("\nplease: history error: %v\n", err)
+			}
+		}
+		if response.Err != nil && repetitions == 1 {
 			var fatalErr PleaseError
-			fatalErr.Err = err
+			fatalErr.Err = options.Redactor.Error(response.Err)
 			fatalErr.ExitCode = 1
 			FatalError(fatalErr)
 		}
 
-		respTimes = append(respTimes, results.RespTime)
-		PrintResults(results)
+		recorder.Record(response)
+		if response.Err != nil {
+			if dashboard == nil {
+				pterm.Println("\n- Request " + strconv.Itoa(response.Index) + ": " + pterm.Red(options.Redactor.Error(response.Err)))
+			}
+			return
+		}
 
-		if createLog {
-			byteQuantity := GenLog(requestUrl, requestType, results, repetitions, i)
-			if byteQuantity > 0 {
+		results = options.Redactor.Results(response.Results)
+		completed++
+		// The chart plots every response, the summary only needs the recorder
+		if options.GenChart {
+			samples = append(samples, NewSample(response.Index, results))
+		}
+		if dashboard == nil {
+			PrintResults(results)
+		}
+
+		if options.CreateLog {
+			byteQuantity := GenLog(results.URL, spec.Method, results, repetitions, response.Index)
+			if byteQuantity > 0 && dashboard == nil {
 				pterm.Println(logFileSuccessfully + pterm.Green(results.Status))
 			}
 		}
+	})
+
+	if dashboard != nil {
+		dashboard.Stop()
 	}
 
-	if genChart && repetitions >= 2 {
-		GenCharts(repetitions, respTimes)
-		pterm.Println("- Chart generated successfully." + pterm.Green(results.Status))
-	} else if genChart && repetitions < 2 {
+	summary := recorder.Summary(time.Since(start))
+	interrupted := ctx.Err() != nil && (repetitions == 0 || summary.Requests < repetitions)
+	if repetitions != 1 || interrupted {
+		PrintSummary(summary, repetitions)
+	}
+
+	if options.SummaryJSON != "" {
+		if err := WriteSummary(options.SummaryJSON, summary); err != nil {
+			var fatalErr PleaseError
+			fatalErr.Err = err
+			fatalErr.ExitCode = 1
+			FatalError(fatalErr)
+		}
+		pterm.Println("- Summary written to " + pterm.LightBlue(options.SummaryJSON))
+	}
+
+	if options.GenChart && completed >= 2 {
+		path, err := GenCharts(samples, summary, options.Chart)
+		if err != nil {
+			fmt.Printf("\nplease: chart generation error: %v\n", err)
+		} else {
+			pterm.Println("- Chart generated successfully: " + pterm.LightBlue(path))
+		}
+	} else if options.GenChart && completed < 2 {
 		fmt.Println("\nplease: chart generation error: there must be at least 2 repetitions.")
 	}
+
+	passed := len(options.Thresholds) == 0 || CheckThresholds(options.Thresholds, summary)
+
+	if interrupted {
+		var fatalErr PleaseError
+		fatalErr.Err = interruptedErrMsg
+		fatalErr.ExitCode = 130
+		FatalError(fatalErr)
+	}
+	if !passed {
+		var fatalErr PleaseError
+		fatalErr.Err = thresholdsErrMsg
+		fatalErr.ExitCode = ThresholdsExitCode
+		FatalError(fatalErr)
+	}
+	if summary.Errors > 0 && len(options.Thresholds) == 0 {
+		var fatalErr PleaseError
+		fatalErr.Err = fmt.Errorf("%w: %d/%d", failedRequestsErrMsg, summary.Errors, summary.Requests)
+		fatalErr.ExitCode = 1
+		FatalError(fatalErr)
+	}
+}
+
+// RequestItems returns the request items that follow the url, nil if there are none
+func RequestItems(args cli.Args, first int) []string {
+	if args.Len() <= first {
+		return nil
+	}
+	return args.Slice()[first:]
 }
 
 func main() {
 	var createLog bool
 	var genChart bool
 	var repetitions int
+	var concurrency int
+	var duration time.Duration
+	var rate string
+	var stages string
+	var repeatSet bool
+	var summaryJSON string
+	var thresholdValues cli.StringSlice
+	var redactValues cli.StringSlice
+	var chartOut string
+	var chartTitle string
+	var chartJSON bool
+	var live bool
+	var historyPath string
+	var noHistory bool
+	var historyMaxSize int
+	var historyLimit int
+	var rerunMethod string
+	var rerunURL string
+	var baseURL string
+	var preserveTiming bool
+	var speed float64
+	var rawBody string
+	var bodyFile string
+	var contentType string
+	var ignoreStdin bool
+	var form bool
+	var multipartForm bool
+	var timeout time.Duration
+	var connectTimeout time.Duration
+	var readTimeout time.Duration
+	var retries int
+	var retryOn string
+	var retryDelay time.Duration
+	var follow bool
+	var noFollow bool
+	var maxRedirects int
+	var keepMethod bool
+
+	// Ctrl-C cancels the request in flight, a second one kills please
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// hasRawBody reports whether the body is given with --raw, --body-file or, if readStdin is set, through stdin
+	hasRawBody := func(readStdin bool) bool {
+		return rawBody != "" || bodyFile != "" || (readStdin && !ignoreStdin && StdinPiped())
+	}
+
+	// requestOptions returns the options of the timeout, retry and redirect flags, for up to concurrency requests in parallel
+	requestOptions := func(concurrency int) (RequestOptions, error) {
+		retryConditions, err := ParseRetryOn(retryOn)
+		if err != nil {
+			return RequestOptions{}, err
+		}
+
+		return RequestOptions{
+			Client:      NewClient(ClientOptions{ConnectTimeout: connectTimeout, Concurrency: concurrency}),
+			Timeout:     timeout,
+			ReadTimeout: readTimeout,
+			Retry: RetryPolicy{
+				Retries: retries,
+				On:      retryConditions,
+				Delay:   retryDelay,
+			},
+			Redirect: RedirectPolicy{
+				NoFollow:   !follow || noFollow || maxRedirects <= 0,
+				Max:        maxRedirects,
+				KeepMethod: keepMethod,
+			},
+		}, nil
+	}
+
+	// newRedactor returns the redactor of the default secrets and of --redact
+	newRedactor := func() (*Redactor, error) {
+		var rules []RedactRule
+		for _, value := range redactValues.Value() {
+			rule, err := ParseRedactRule(value)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, rule)
+		}
+		return NewRedactor(rules), nil
+	}
+
+	// openHistory returns the history of --history, nil if it's disabled
+	openHistory := func() *History {
+		if noHistory || historyPath == "" {
+			return nil
+		}
+		return NewHistory(historyPath, historyMaxSize)
+	}
+
+	// send builds the request with build, from the body given with --raw, --body-file or stdin and the body mode,
+	// and sends it as described by the command line
+	send := func(build func(body *RawBody, mode BodyMode) (RequestSpec, error), readStdin bool) error {
+		var body *RawBody
+		var err error
+		if hasRawBody(readStdin) {
+			// Stdin can be streamed only if the body is sent once
+			body, err = NewRawBody(rawBody, bodyFile, contentType, repetitions != 1 || duration > 0 || stages != "" || retries > 0)
+		}
+
+		profile := LoadProfile{
+			Repetitions: repetitions,
+			Duration:    duration,
+			Concurrency: concurrency,
+		}
+		// A run with a duration isn't limited by the default number of repetitions
+		if (duration > 0 || stages != "") && !repeatSet {
+			profile.Repetitions = 0
+		}
+		if err == nil && rate != "" {
+			profile.Rate, err = ParseRate(rate)
+		}
+		if err == nil && stages != "" {
+			profile.Stages, err = ParseStages(stages)
+		}
+
+		var thresholds []Threshold
+		for _, value := range thresholdValues.Value() {
+			if err != nil {
+				break
+			}
+			var threshold Threshold
+			threshold, err = ParseThreshold(value)
+			thresholds = append(thresholds, threshold)
+		}
+
+		var redactor *Redactor
+		if err == nil {
+			redactor, err = newRedactor()
+		}
+
+		var options RequestOptions
+		if err == nil {
+			options, err = requestOptions(max(concurrency, int(math.Ceil(profile.PeakRate()))))
+		}
+
+		mode := JSONMode
+		if multipartForm {
+			mode = MultipartMode
+		} else if form {
+			mode = FormMode
+		}
+
+		var spec RequestSpec
+		if err == nil {
+			spec, err = build(body, mode)
+		}
+		if err != nil {
+			var fatalErr PleaseError
+			fatalErr.Err = redactor.Error(err)
+			fatalErr.ExitCode = 1
+			FatalError(fatalErr)
+		}
+
+		spec.Options = options
+
+		Request(ctx, spec, RunOptions{
+			CreateLog: createLog,
+			GenChart:  genChart || chartOut != "" || chartJSON,
+			Profile:   profile,
+			Chart: ChartOptions{
+				Out:   chartOut,
+				Title: chartTitle,
+				JSON:  chartJSON,
+			},
+			SummaryJSON: summaryJSON,
+			Thresholds:  thresholds,
+			Live:        live,
+			History:     openHistory(),
+			Redactor:    redactor,
+		})
+		return nil
+	}
+
+	// run builds the request described by the command line and sends it
+	run := func(method string, requestUrl string, items []string, readStdin bool) error {
+		return send(func(body *RawBody, mode BodyMode) (RequestSpec, error) {
+			return NewRequestSpec(method, requestUrl, items, mode, body)
+		}, readStdin)
+	}
+
+	// readHistory reads the history of --history, it exits if it can't be read
+	readHistory := func() []HistoryEntry {
+		entries, err := ReadHistory(historyPath)
+		if err != nil {
+			var fatalErr PleaseError
+			fatalErr.Err = err
+			fatalErr.ExitCode = 1
+			FatalError(fatalErr)
+		}
+		return entries
+	}
+
+	// findHistoryEntry returns the request of the history with the id given as first argument, it exits if there is none
+	findHistoryEntry := func(cCtx *cli.Context) HistoryEntry {
+		if cCtx.Args().Len() < 1 {
+			var fatalErr PleaseError
+			fatalErr.Err = fewArgsErrMsg
+			fatalErr.ExitCode = 1
+			FatalError(fatalErr)
+		}
+		entry, err := FindHistoryEntry(readHistory(), cCtx.Args().First())
+		if err != nil {
+			var fatalErr PleaseError
+			fatalErr.Err = err
+			fatalErr.ExitCode = 1
+			FatalError(fatalErr)
+		}
+		return entry
+	}
 
 	app := &cli.App{
 		Name:  "please",
@@ -138,6 +521,21 @@ func main() {
 				Usage:       "generate a response time chart and must be called with the --repeat flag (--repeat=n, n>= 2)",
 				Destination: &genChart,
 			},
+			&cli.StringFlag{
+				Name:        "chart-out",
+				Usage:       "write the chart to `path`, or to a timestamped file in path if it's a directory (implies --gen-chart)",
+				Destination: &chartOut,
+			},
+			&cli.StringFlag{
+				Name:        "chart-title",
+				Usage:       "title of the chart page",
+				Destination: &chartTitle,
+			},
+			&cli.BoolFlag{
+				Name:        "chart-json",
+				Usage:       "also write the chart data as JSON next to the chart (implies --gen-chart)",
+				Destination: &chartJSON,
+			},
 			&cli.IntFlag{
 				Name:        "repeat",
 				Aliases:     []string{"r"},
@@ -145,113 +543,481 @@ func main() {
 				Usage:       "repeat a request n times",
 				Destination: &repetitions,
 			},
+			&cli.IntFlag{
+				Name:        "concurrency",
+				Aliases:     []string{"C"},
+				Value:       0,
+				Usage:       "send up to n of the repeated requests in parallel (default: 1, unlimited with --rate or --stages)",
+				Destination: &concurrency,
+			},
+			&cli.BoolFlag{
+				Name:        "live",
+				Usage:       "show a live dashboard of the run instead of every response",
+				Destination: &live,
+			},
+			&cli.StringSliceFlag{
+				Name:        "redact",
+				Usage:       "hide a header, a query parameter or a JSON field in the output, the logs and the history, e.g. 'header:X-Token', 'query:token' or 'json:user.ssn', can be repeated",
+				Destination: &redactValues,
+			},
+			&cli.StringFlag{
+				Name:        "history",
+				Value:       DefaultHistoryPath(),
+				Usage:       "append a JSON Lines record of every request to `path`",
+				EnvVars:     []string{"PLEASE_HISTORY"},
+				Destination: &historyPath,
+			},
+			&cli.BoolFlag{
+				Name:        "no-history",
+				Usage:       "don't record the requests in the history",
+				Destination: &noHistory,
+			},
+			&cli.IntFlag{
+				Name:        "history-max-size",
+				Value:       DefaultHistoryMaxSize,
+				Usage:       "rotate the history when it grows above n MB, keeping " + strconv.Itoa(HistoryBackups) + " old files (0 never rotates it)",
+				Destination: &historyMaxSize,
+			},
+			&cli.StringFlag{
+				Name:        "summary-json",
+				Usage:       "write the summary of the run as JSON to `path`",
+				Destination: &summaryJSON,
+			},
+			&cli.StringSliceFlag{
+				Name:        "threshold",
+				Usage:       "fail the run with exit status 99 unless the statistic passes, e.g. 'p95<300ms' or 'error_rate<1%', can be repeated",
+				Destination: &thresholdValues,
+			},
+			&cli.DurationFlag{
+				Name:        "duration",
+				Aliases:     []string{"d"},
+				Usage:       "keep sending the request for the given duration, e.g. 30s",
+				Destination: &duration,
+			},
+			&cli.StringFlag{
+				Name:        "rate",
+				Usage:       "start the requests at a constant rate whether or not the previous ones completed, e.g. 200/s",
+				Destination: &rate,
+			},
+			&cli.StringFlag{
+				Name:        "stages",
+				Usage:       "ramp the rate of requests linearly through duration:rate stages, e.g. 10s:50,30s:200,10s:0",
+				Destination: &stages,
+			},
+			&cli.StringFlag{
+				Name:        "raw",
+				Usage:       "send the given string as the request body",
+				Destination: &rawBody,
+			},
+			&cli.StringFlag{
+				Name:        "body-file",
+				Usage:       "stream the content of the file as the request body",
+				Destination: &bodyFile,
+			},
+			&cli.StringFlag{
+				Name:        "content-type",
+				Usage:       "Content-Type of the body given with --raw, --body-file or through stdin",
+				Destination: &contentType,
+			},
+			&cli.BoolFlag{
+				Name:        "ignore-stdin",
+				Value:       false,
+				Usage:       "don't read the request body from stdin when it's piped",
+				Destination: &ignoreStdin,
+			},
+			&cli.BoolFlag{
+				Name:        "form",
+				Aliases:     []string{"f"},
+				Value:       false,
+				Usage:       "send the body items as application/x-www-form-urlencoded, or multipart/form-data if there are files",
+				Destination: &form,
+			},
+			&cli.BoolFlag{
+				Name:        "multipart",
+				Value:       false,
+				Usage:       "send the body items and the files as multipart/form-data",
+				Destination: &multipartForm,
+			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Aliases:     []string{"t"},
+				Usage:       "abort a request that takes longer than the given duration, e.g. 10s",
+				Destination: &timeout,
+			},
+			&cli.DurationFlag{
+				Name:        "connect-timeout",
+				Usage:       "abort a request if the connection isn't established within the given duration",
+				Destination: &connectTimeout,
+			},
+			&cli.DurationFlag{
+				Name:        "read-timeout",
+				Usage:       "abort a request if the server sends nothing for the given duration",
+				Destination: &readTimeout,
+			},
+			&cli.IntFlag{
+				Name:        "retries",
+				Value:       0,
+				Usage:       "retry a failed request up to n times",
+				Destination: &retries,
+			},
+			&cli.StringFlag{
+				Name:        "retry-on",
+				Value:       DefaultRetryOn,
+				Usage:       "comma separated failures to retry: status codes, status classes (5xx), connect-error, timeout",
+				Destination: &retryOn,
+			},
+			&cli.DurationFlag{
+				Name:        "retry-delay",
+				Value:       DefaultRetryDelay,
+				Usage:       "delay before the first retry, doubled at every retry (the Retry-After header takes precedence)",
+				Destination: &retryDelay,
+			},
+			&cli.BoolFlag{
+				Name:        "follow",
+				Value:       true,
+				Usage:       "follow redirects",
+				Destination: &follow,
+			},
+			&cli.BoolFlag{
+				Name:        "no-follow",
+				Value:       false,
+				Usage:       "don't follow redirects",
+				Destination: &noFollow,
+			},
+			&cli.IntFlag{
+				Name:        "max-redirects",
+				Value:       DefaultMaxRedirects,
+				Usage:       "maximum number of redirects to follow",
+				Destination: &maxRedirects,
+			},
+			&cli.BoolFlag{
+				Name:        "keep-method",
+				Value:       false,
+				Usage:       "re-send the method and the body on 301 and 302 redirects instead of switching to GET (they are always re-sent on 307 and 308)",
+				Destination: &keepMethod,
+			},
 		},
 		Commands: []*cli.Command{
 			{
 				Name:  "get",
 				Usage: "Make a GET request.\tE.g: please get https://httpbin.org/get",
 				Action: func(cCtx *cli.Context) error {
-					requestUrl := cCtx.Args().Get(0)
-					Request(GET, requestUrl, createLog, genChart, repetitions, nil)
-					return nil
+					return run(GET, cCtx.Args().Get(0), RequestItems(cCtx.Args(), 1), false)
 				},
 			},
 			{
 				Name:  "post",
 				Usage: "Make a POST request.\tE.g: please post https://httpbin.org/post foo=bar",
 				Action: func(cCtx *cli.Context) error {
-					requestUrl := cCtx.Args().Get(0)
-
-					if cCtx.Args().Len() < 2 {
+					if cCtx.Args().Len() < 2 && !hasRawBody(true) {
 						var fatalErr PleaseError
 						fatalErr.Err = fewArgsErrMsg
 						fatalErr.ExitCode = 1
 						FatalError(fatalErr)
 					}
-
-					var keysValues []string
-					for i := 1; i <= cCtx.Args().Len(); i++ {
-						keysValues = append(keysValues, cCtx.Args().Get(i))
-					}
-
-					Request(POST, requestUrl, createLog, genChart, repetitions, keysValues)
-					return nil
+					return run(POST, cCtx.Args().Get(0), RequestItems(cCtx.Args(), 1), true)
 				},
 			},
 			{
 				Name:  "put",
 				Usage: "Make a PUT request.\tE.g: please put https://httpbin.org/put foo=bar",
 				Action: func(cCtx *cli.Context) error {
-					requestUrl := cCtx.Args().Get(0)
-
-					if cCtx.Args().Len() < 2 {
+					if cCtx.Args().Len() < 2 && !hasRawBody(true) {
 						var fatalErr PleaseError
 						fatalErr.Err = fewArgsErrMsg
 						fatalErr.ExitCode = 1
 						FatalError(fatalErr)
 					}
-
-					var keysValues []string
-					for i := 1; i <= cCtx.Args().Len(); i++ {
-						keysValues = append(keysValues, cCtx.Args().Get(i))
-					}
-
-					Request(PUT, requestUrl, createLog, genChart, repetitions, keysValues)
-					return nil
+					return run(PUT, cCtx.Args().Get(0), RequestItems(cCtx.Args(), 1), true)
 				},
 			},
 			{
 				Name:  "patch",
 				Usage: "Make a PATCH request.\tE.g: please patch https://httpbin.org/patch foo=bar",
 				Action: func(cCtx *cli.Context) error {
-					requestUrl := cCtx.Args().Get(0)
-
-					if cCtx.Args().Len() < 2 {
+					if cCtx.Args().Len() < 2 && !hasRawBody(true) {
 						var fatalErr PleaseError
 						fatalErr.Err = fewArgsErrMsg
 						fatalErr.ExitCode = 1
 						FatalError(fatalErr)
 					}
-
-					var keysValues []string
-					for i := 1; i <= cCtx.Args().Len(); i++ {
-						keysValues = append(keysValues, cCtx.Args().Get(i))
-					}
-
-					Request(PATCH, requestUrl, createLog, genChart, repetitions, keysValues)
-					return nil
+					return run(PATCH, cCtx.Args().Get(0), RequestItems(cCtx.Args(), 1), true)
 				},
 			},
 			{
 				Name:  "delete",
 				Usage: "Make a DELETE request.\tE.g: please delete https://httpbin.org/delete",
 				Action: func(cCtx *cli.Context) error {
-					requestUrl := cCtx.Args().Get(0)
-					Request(DELETE, requestUrl, createLog, genChart, repetitions, nil)
-					return nil
+					return run(DELETE, cCtx.Args().Get(0), RequestItems(cCtx.Args(), 1), false)
 				},
 			},
 			{
 				Name:  "head",
 				Usage: "Make a HEAD request.\tE.g: please head https://httpbin.org/",
 				Action: func(cCtx *cli.Context) error {
-					requestUrl := cCtx.Args().Get(0)
-					Request(HEAD, requestUrl, createLog, genChart, repetitions, nil)
-					return nil
+					return run(HEAD, cCtx.Args().Get(0), RequestItems(cCtx.Args(), 1), false)
 				},
 			},
 			{
 				Name:  "options",
 				Usage: "Make a OPTIONS request.\tE.g: please options https://httpbin.org/",
 				Action: func(cCtx *cli.Context) error {
-					requestUrl := cCtx.Args().Get(0)
-					Request(OPTIONS, requestUrl, createLog, genChart, repetitions, nil)
+					return run(OPTIONS, cCtx.Args().Get(0), RequestItems(cCtx.Args(), 1), false)
+				},
+			},
+			{
+				Name:      "chart",
+				Usage:     "Chart the requests of log files, logs dirs or JSON Lines files.\tE.g: please chart logs/",
+				ArgsUsage: "<logs dir|log file|jsonl file>...",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 1 {
+						var fatalErr PleaseError
+						fatalErr.Err = fewArgsErrMsg
+						fatalErr.ExitCode = 1
+						FatalError(fatalErr)
+					}
+
+					path, err := ChartLogs(cCtx.Args().Slice(), ChartOptions{Out: chartOut, Title: chartTitle, JSON: chartJSON})
+					if err != nil {
+						var fatalErr PleaseError
+						fatalErr.Err = err
+						fatalErr.ExitCode = 1
+						FatalError(fatalErr)
+					}
+					pterm.Println("- Chart generated successfully: " + pterm.LightBlue(path))
+					return nil
+				},
+			},
+			{
+				Name:      "compare",
+				Usage:     "Compare runs saved as logs, --summary-json or --chart-json files.\tE.g: please compare before/logs after/logs",
+				ArgsUsage: "<run> <run> [runs]",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 2 {
+						var fatalErr PleaseError
+						fatalErr.Err = fewArgsErrMsg
+						fatalErr.ExitCode = 1
+						FatalError(fatalErr)
+					}
+
+					path, err := CompareRuns(cCtx.Args().Slice(), ChartOptions{Out: chartOut, Title: chartTitle})
+					if err != nil {
+						var fatalErr PleaseError
+						fatalErr.Err = err
+						fatalErr.ExitCode = 1
+						FatalError(fatalErr)
+					}
+					pterm.Println("- Chart generated successfully: " + pterm.LightBlue(path))
 					return nil
 				},
 			},
+			{
+				Name:      "request",
+				Usage:     "Make a request with any method.\tE.g: please request PROPFIND https://httpbin.org/anything",
+				ArgsUsage: "<METHOD> <url> [items]",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 2 {
+						var fatalErr PleaseError
+						fatalErr.Err = fewArgsErrMsg
+						fatalErr.ExitCode = 1
+						FatalError(fatalErr)
+					}
+					return run(cCtx.Args().Get(0), cCtx.Args().Get(1), RequestItems(cCtx.Args(), 2), true)
+				},
+			},
+			{
+				Name:      "replay",
+				Usage:     "Send the requests of a history file or of logs again and compare the responses.\tE.g: please replay --base-url=http://localhost:8080 history.jsonl",
+				ArgsUsage: "<history file|logs dir|log file>...",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "base-url",
+						Usage:       "send the requests to another server, e.g. http://localhost:8080, its path is prepended to the recorded paths",
+						Destination: &baseURL,
+					},
+					&cli.BoolFlag{
+						Name:        "preserve-timing",
+						Usage:       "wait between the requests as long as when they were recorded",
+						Destination: &preserveTiming,
+					},
+					&cli.Float64Flag{
+						Name:        "speed",
+						Value:       1,
+						Usage:       "replay the recorded timing n times faster, implies --preserve-timing",
+						Destination: &speed,
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() < 1 {
+						var fatalErr PleaseError
+						fatalErr.Err = fewArgsErrMsg
+						fatalErr.ExitCode = 1
+						FatalError(fatalErr)
+					}
+
+					entries, err := ReadRecordedRequests(cCtx.Args().Slice())
+					if err == nil && baseURL != "" {
+						_, err = RebaseURL("http://localhost/", baseURL)
+					}
+					if err == nil && speed <= 0 {
+						err = fmt.Errorf("%w: %v", invalidSpeedErrMsg, speed)
+					}
+					var redactor *Redactor
+					if err == nil {
+						redactor, err = newRedactor()
+					}
+					var options RequestOptions
+					if err == nil {
+						options, err = requestOptions(1)
+					}
+					if err != nil {
+						var fatalErr PleaseError
+						fatalErr.Err = err
+						fatalErr.ExitCode = 1
+						FatalError(fatalErr)
+					}
+
+					replayOptions := ReplayOptions{BaseURL: baseURL, History: openHistory(), Redactor: redactor}
+					if preserveTiming || cCtx.IsSet("speed") {
+						replayOptions.Speed = speed
+					}
+					if redacted := RedactedHeaders(entries); len(redacted) > 0 {
+						pterm.Println("- Redacted headers aren't sent again: " + pterm.Yellow(strings.Join(redacted, ", ")))
+					}
+
+					var results []ReplayResult
+					start := time.Now()
+					Replay(ctx, entries, options, replayOptions, func(result ReplayResult) {
+						result.Response.Results = redactor.Results(result.Response.Results)
+						result.Response.Err = redactor.Error(result.Response.Err)
+						results = append(results, result)
+						PrintReplayResult(result)
+					})
+
+					report := NewReplayReport(results, time.Since(start))
+					if len(results) > 0 {
+						PrintReplayReport(report)
+					}
+
+					if ctx.Err() != nil {
+						var fatalErr PleaseError
+						fatalErr.Err = interruptedErrMsg
+						fatalErr.ExitCode = 130
+						FatalError(fatalErr)
+					}
+					if report.StatusChanges > 0 {
+						var fatalErr PleaseError
+						fatalErr.Err = fmt.Errorf("%w: %d/%d", replayMismatchErrMsg, report.StatusChanges, len(results))
+						fatalErr.ExitCode = 1
+						FatalError(fatalErr)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "history",
+				Usage: "List the last requests of the history.\tE.g: please history",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:        "limit",
+						Aliases:     []string{"n"},
+						Value:       DefaultHistoryLimit,
+						Usage:       "list the last n requests",
+						Destination: &historyLimit,
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					entries := readHistory()
+					if len(entries) == 0 {
+						pterm.Println("- The history is empty.")
+						return nil
+					}
+					PrintHistory(entries[max(len(entries)-historyLimit, 0):])
+					return nil
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:      "search",
+						Usage:     "Fuzzy search the requests by method, url and status.\tE.g: please history search post users",
+						ArgsUsage: "<query>",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:        "limit",
+								Aliases:     []string{"n"},
+								Value:       DefaultHistoryLimit,
+								Usage:       "list the n best matches",
+								Destination: &historyLimit,
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Len() < 1 {
+								var fatalErr PleaseError
+								fatalErr.Err = fewArgsErrMsg
+								fatalErr.ExitCode = 1
+								FatalError(fatalErr)
+							}
+
+							found := SearchHistory(readHistory(), strings.Join(cCtx.Args().Slice(), " "))
+							if len(found) == 0 {
+								pterm.Println("- No request matches.")
+								return nil
+							}
+							PrintHistory(found[:min(len(found), historyLimit)])
+							return nil
+						},
+					},
+					{
+						Name:      "show",
+						Usage:     "Show a request of the history.\tE.g: please history show 42",
+						ArgsUsage: "<id>",
+						Action: func(cCtx *cli.Context) error {
+							PrintHistoryEntry(findHistoryEntry(cCtx))
+							return nil
+						},
+					},
+					{
+						Name:      "rerun",
+						Usage:     "Send a request of the history again, the items override its headers and body.\tE.g: please history rerun 42 Authorization:'Bearer token'",
+						ArgsUsage: "<id> [items]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:        "method",
+								Aliases:     []string{"X"},
+								Usage:       "send the request with another method",
+								Destination: &rerunMethod,
+							},
+							&cli.StringFlag{
+								Name:        "url",
+								Usage:       "send the request to another url",
+								Destination: &rerunURL,
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							entry := findHistoryEntry(cCtx)
+							if rerunMethod != "" {
+								entry.Method = strings.ToUpper(rerunMethod)
+							}
+							if rerunURL != "" {
+								entry.URL = rerunURL
+							}
+							if redacted := entry.RedactedHeaders(); len(redacted) > 0 {
+								pterm.Println("- Redacted headers aren't sent again: " + pterm.Yellow(strings.Join(redacted, ", ")))
+							}
+
+							return send(func(body *RawBody, mode BodyMode) (RequestSpec, error) {
+								return entry.Spec(RequestItems(cCtx.Args(), 1), mode, body)
+							}, false)
+						},
+					},
+				},
+			},
+		},
+		Before: func(cCtx *cli.Context) error {
+			repeatSet = cCtx.IsSet("repeat")
+			return nil
 		},
 		Action:  nil,
-		Version: "0.3.1",
+		Version: Version,
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response is the outcome of the request number Index of a run, starting from 1
+type Response struct {
+	Index   int
+	Results Results
+	Err     error
+}
+
+// LoadProfile tells how many requests a run sends and when
+type LoadProfile struct {
+	// Repetitions is the number of requests, unlimited if 0
+	Repetitions int
+	// Duration stops sending new requests once elapsed, unlimited if 0
+	Duration time.Duration
+	// Concurrency is the number of workers of a closed model run,
+	// or the maximum number of requests in flight of an open model run, unlimited if 0
+	Concurrency int
+	// Rate or Stages make an open model run: requests are started at a constant, or ramping,
+	// number of requests per second whether or not the previous ones have completed
+	Rate   float64
+	Stages []Stage
+}
+
+// Stage ramps linearly the rate of requests from the rate of the previous stage to Rate
+type Stage struct {
+	Duration time.Duration
+	Rate     float64
+}
+
+// OpenModel reports whether requests are sent at a given rate
+func (profile LoadProfile) OpenModel() bool {
+	return profile.Rate > 0 || len(profile.Stages) > 0
+}
+
+// PeakRate returns the highest number of requests per second of an open model run
+func (profile LoadProfile) PeakRate() float64 {
+	peak := profile.Rate
+	for _, stage := range profile.Stages {
+		peak = max(peak, stage.Rate)
+	}
+	return peak
+}
+
+// Arrival returns when the n-th request, starting from 0, must be sent
+// relative to the start of an open model run, false if it must not be sent
+func (profile LoadProfile) Arrival(n int) (time.Duration, bool) {
+	if len(profile.Stages) == 0 {
+		return time.Duration(float64(n) / profile.Rate * float64(time.Second)), true
+	}
+
+	var elapsed time.Duration
+	var count float64
+	var from float64
+	for _, stage := range profile.Stages {
+		seconds := stage.Duration.Seconds()
+		stageCount := (from + stage.Rate) / 2 * seconds
+
+		if k := float64(n) - count; k < stageCount {
+			// Solve from*t + slope*t²/2 = k, the number of requests sent t seconds into the stage
+			var t float64
+			if slope := (stage.Rate - from) / seconds; slope == 0 {
+				t = k / from
+			} else {
+				t = (-from + math.Sqrt(from*from+2*slope*k)) / slope
+			}
+			return elapsed + time.Duration(t*float64(time.Second)), true
+		}
+
+		count += stageCount
+		elapsed += stage.Duration
+		from = stage.Rate
+	}
+
+	return 0, false
+}
+
+// job is a request to send, scheduled is zero in a closed model run
+type job struct {
+	index     int
+	scheduled time.Time
+}
+
+// RunPool sends the request as described by profile.
+// The responses are passed to handle in request order, from the calling goroutine.
+// arrived, unless nil, is called first with every response as soon as it arrives, out of order,
+// so that a slow request doesn't hold back the progress of a run.
+// No request is started once ctx is done or the duration of the run has elapsed.
+func RunPool(ctx context.Context, spec RequestSpec, profile LoadProfile, arrived func(Response), handle func(Response)) {
+	responses := make(chan Response)
+
+	// feedCtx stops the scheduling of new requests, while those in flight can complete
+	feedCtx := ctx
+	if profile.Duration > 0 {
+		var cancel context.CancelFunc
+		feedCtx, cancel = context.WithTimeout(ctx, profile.Duration)
+		defer cancel()
+	}
+
+	var wg sync.WaitGroup
+	if profile.OpenModel() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scheduleArrivals(feedCtx, ctx, spec, profile, responses, &wg)
+		}()
+	} else {
+		jobs := make(chan job)
+		go func() {
+			defer close(jobs)
+			for i := 1; profile.Repetitions == 0 || i <= profile.Repetitions; i++ {
+				select {
+				case jobs <- job{index: i}:
+				case <-feedCtx.Done():
+					return
+				}
+			}
+		}()
+
+		workers := max(1, profile.Concurrency)
+		if profile.Repetitions > 0 {
+			workers = min(workers, profile.Repetitions)
+		}
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for job := range jobs {
+					responses <- sendJob(ctx, spec, job)
+				}
+			}()
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(responses)
+	}()
+
+	// Responses that arrived before the ones of the previous requests
+	pending := make(map[int]Response)
+	next := 1
+	for response := range responses {
+		if arrived != nil {
+			arrived(response)
+		}
+		pending[response.Index] = response
+		for {
+			response, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			handle(response)
+			next++
+		}
+	}
+}
+
+// scheduleArrivals starts every request of an open model run at its scheduled time.
+// Every started request sends its response, even if it has been cancelled while waiting for a free slot.
+func scheduleArrivals(feedCtx context.Context, ctx context.Context, spec RequestSpec, profile LoadProfile, responses chan<- Response, wg *sync.WaitGroup) {
+	var slots chan struct{}
+	if profile.Concurrency > 0 {
+		slots = make(chan struct{}, profile.Concurrency)
+	}
+
+	start := time.Now()
+
+	for n := 0; profile.Repetitions == 0 || n < profile.Repetitions; n++ {
+		offset, ok := profile.Arrival(n)
+		if !ok {
+			return
+		}
+		scheduled := start.Add(offset)
+
+		if err := sleep(feedCtx, time.Until(scheduled)); err != nil {
+			return
+		}
+
+		wg.Add(1)
+		go func(job job) {
+			defer wg.Done()
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					responses <- Response{Index: job.index, Err: context.Cause(ctx)}
+					return
+				}
+			}
+			responses <- sendJob(ctx, spec, job)
+		}(job{index: n + 1, scheduled: scheduled})
+	}
+}
+
+// sendJob sends the request of job.
+// The response time of a scheduled request is measured from its scheduled time
+// so that the time spent waiting for a free slot isn't omitted.
+func sendJob(ctx context.Context, spec RequestSpec, job job) Response {
+	results, err := Do(ctx, spec)
+	if err == nil && !job.scheduled.IsZero() {
+		results.Lag = max(results.StartTime.Sub(job.scheduled), 0)
+		results.RespTime = (results.Timings.Total + results.Lag).Milliseconds()
+	}
+	return Response{Index: job.index, Results: results, Err: err}
+}
+
+// ParseRate parses a number of requests per time unit, e.g. 200/s, 30/m or 5/100ms.
+// A number without unit is per second.
+func ParseRate(value string) (float64, error) {
+	count, unit, found := strings.Cut(strings.TrimSpace(value), "/")
+
+	requests, err := strconv.ParseFloat(count, 64)
+	if err != nil || requests < 0 {
+		return 0, fmt.Errorf("%w: %s", invalidRateErrMsg, value)
+	}
+	if !found {
+		return requests, nil
+	}
+
+	// A unit without number is a single unit, e.g. s is 1s
+	if unit != "" && (unit[0] < '0' || unit[0] > '9') {
+		unit = "1" + unit
+	}
+	per, err := time.ParseDuration(unit)
+	if err != nil || per <= 0 {
+		return 0, fmt.Errorf("%w: %s", invalidRateErrMsg, value)
+	}
+
+	return requests / per.Seconds(), nil
+}
+
+// ParseStages parses a comma separated list of duration:rate stages, e.g. 10s:50,30s:200,10s:0
+func ParseStages(value string) ([]Stage, error) {
+	var stages []Stage
+
+	for _, stage := range strings.Split(value, ",") {
+		duration, rate, found := strings.Cut(strings.TrimSpace(stage), ":")
+		if !found {
+			return nil, fmt.Errorf("%w: %s", invalidStageErrMsg, stage)
+		}
+
+		stageDuration, err := time.ParseDuration(duration)
+		if err != nil || stageDuration <= 0 {
+			return nil, fmt.Errorf("%w: %s", invalidStageErrMsg, stage)
+		}
+		stageRate, err := ParseRate(rate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", invalidStageErrMsg, stage)
+		}
+
+		stages = append(stages, Stage{Duration: stageDuration, Rate: stageRate})
+	}
+
+	return stages, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPoolOrderedResponses(t *testing.T) {
+	var calls, inFlight, maxInFlight atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := maxInFlight.Load()
+			if current <= previous || maxInFlight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+
+		// Later requests answer sooner so that responses arrive out of order
+		call := calls.Add(1)
+		time.Sleep(time.Duration(20-call) * time.Millisecond)
+		w.Header().Set("X-Call", strconv.FormatInt(call, 10))
+	}))
+	t.Cleanup(server.Close)
+
+	spec, err := NewRequestSpec(GET, server.URL, nil, JSONMode, nil)
+	assert.NoError(t, err)
+	spec.Options.Client = NewClient(ClientOptions{Concurrency: 4})
+
+	var arrivals, indexes []int
+	arrived := func(response Response) {
+		arrivals = append(arrivals, response.Index)
+	}
+	RunPool(context.Background(), spec, LoadProfile{Repetitions: 12, Concurrency: 4}, arrived, func(response Response) {
+		assert.NoError(t, response.Err)
+		indexes = append(indexes, response.Index)
+		// Every response arrived before it's handled
+		assert.Contains(t, arrivals, response.Index)
+	})
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, indexes)
+	assert.ElementsMatch(t, indexes, arrivals)
+	assert.Equal(t, int64(12), calls.Load())
+	assert.Equal(t, int64(4), maxInFlight.Load())
+}
+
+func TestRunPoolCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	spec, err := NewRequestSpec(GET, server.URL, nil, JSONMode, nil)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(35*time.Millisecond, cancel)
+
+	var completed int
+	RunPool(ctx, spec, LoadProfile{Repetitions: 1000, Concurrency: 2}, nil, func(response Response) {
+		if response.Err == nil {
+			completed++
+		}
+	})
+
+	assert.Positive(t, completed)
+	assert.Less(t, completed, 20)
+}
+
+func TestRunPoolDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	t.Cleanup(server.Close)
+
+	spec, err := NewRequestSpec(GET, server.URL, nil, JSONMode, nil)
+	assert.NoError(t, err)
+
+	var completed int
+	start := time.Now()
+	RunPool(context.Background(), spec, LoadProfile{Duration: 50 * time.Millisecond, Concurrency: 2}, nil, func(response Response) {
+		assert.NoError(t, response.Err)
+		completed++
+	})
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Positive(t, completed)
+	assert.Less(t, completed, 20)
+}
+
+func TestRunPoolRate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	spec, err := NewRequestSpec(GET, server.URL, nil, JSONMode, nil)
+	assert.NoError(t, err)
+
+	var indexes []int
+	start := time.Now()
+	RunPool(context.Background(), spec, LoadProfile{Repetitions: 5, Rate: 100}, nil, func(response Response) {
+		assert.NoError(t, response.Err)
+		assert.GreaterOrEqual(t, response.Results.Lag, time.Duration(0))
+		indexes = append(indexes, response.Index)
+	})
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, indexes)
+	// The last request is scheduled 40ms after the first one
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+}
+
+func TestArrival(t *testing.T) {
+	constant := LoadProfile{Rate: 200}
+	offset, ok := constant.Arrival(10)
+	assert.True(t, ok)
+	assert.Equal(t, 50*time.Millisecond, offset)
+
+	// 1s from 0 to 10/s sends 5 requests, then 1s at 10/s sends 10
+	ramp := LoadProfile{Stages: []Stage{{Duration: time.Second, Rate: 10}, {Duration: time.Second, Rate: 10}}}
+	offset, ok = ramp.Arrival(0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), offset)
+	offset, ok = ramp.Arrival(5)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, offset)
+	offset, ok = ramp.Arrival(14)
+	assert.True(t, ok)
+	assert.Equal(t, 1900*time.Millisecond, offset)
+	_, ok = ramp.Arrival(15)
+	assert.False(t, ok)
+	assert.Equal(t, 10.0, ramp.PeakRate())
+}
+
+func TestParseRate(t *testing.T) {
+	for value, expected := range map[string]float64{"200/s": 200, "30/m": 0.5, "5/100ms": 50, "200": 200, "1/2s": 0.5} {
+		rate, err := ParseRate(value)
+		assert.NoError(t, err, value)
+		assert.InDelta(t, expected, rate, 1e-9, value)
+	}
+
+	for _, value := range []string{"", "fast", "10/", "10/x", "-1/s", "10/0s"} {
+		_, err := ParseRate(value)
+		assert.ErrorIs(t, err, invalidRateErrMsg, value)
+	}
+}
+
+func TestParseStages(t *testing.T) {
+	stages, err := ParseStages("10s:50, 30s:200/s,10s:0")
+	assert.NoError(t, err)
+	assert.Equal(t, []Stage{{10 * time.Second, 50}, {30 * time.Second, 200}, {10 * time.Second, 0}}, stages)
+
+	for _, value := range []string{"", "10s", "10s:fast", "0s:10", "x:10"} {
+		_, err := ParseStages(value)
+		assert.ErrorIs(t, err, invalidStageErrMsg, value)
+	}
+}
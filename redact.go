@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Redacted replaces the secrets in the output, the logs and the history
+const Redacted = "[REDACTED]"
+
+// defaultRedactedHeaders are always redacted
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// defaultRedactedQueries are the query parameters always redacted, whatever their case
+var defaultRedactedQueries = []string{"api_key", "apikey", "access_token"}
+
+// RedactRule hides either a header, a query parameter or a JSON field, as given to --redact
+type RedactRule struct {
+	Header string
+	Query  string
+	// JSONPath is a dot separated path of keys or array indexes, "*" matches any of them
+	JSONPath []string
+}
+
+// ParseRedactRule parses a header:Name, query:name or json:path rule, e.g. json:user.tokens.*.value
+func ParseRedactRule(value string) (RedactRule, error) {
+	kind, name, _ := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+	case kind == "header":
+		return RedactRule{Header: name}, nil
+	case kind == "query":
+		return RedactRule{Query: name}, nil
+	case kind == "json":
+		return RedactRule{JSONPath: strings.Split(name, ".")}, nil
+	}
+	return RedactRule{}, invalidRedactRuleErrMsg
+}
+
+// Redactor hides the secrets of the requests and of the responses before they are printed or written.
+// The default headers and query parameters, the passwords of the urls and the password fields of JSON bodies
+// are always hidden.
+// A nil Redactor leaves everything as is.
+type Redactor struct {
+	headers   map[string]bool
+	queries   map[string]bool
+	jsonPaths [][]string
+}
+
+// NewRedactor returns a redactor of the defaults and of rules
+func NewRedactor(rules []RedactRule) *Redactor {
+	redactor := &Redactor{headers: make(map[string]bool), queries: make(map[string]bool)}
+	for _, name := range defaultRedactedHeaders {
+		redactor.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, name := range defaultRedactedQueries {
+		redactor.queries[name] = true
+	}
+	for _, rule := range rules {
+		if rule.Header != "" {
+			redactor.headers[http.CanonicalHeaderKey(rule.Header)] = true
+		} else if rule.Query != "" {
+			redactor.queries[strings.ToLower(rule.Query)] = true
+		} else {
+			redactor.jsonPaths = append(redactor.jsonPaths, rule.JSONPath)
+		}
+	}
+	return redactor
+}
+
+// Headers returns a copy of headers with the values of the redacted ones hidden
+func (redactor *Redactor) Headers(headers http.Header) http.Header {
+	if redactor == nil || headers == nil {
+		return headers
+	}
+
+	redacted := make(http.Header, len(headers))
+	for name, values := range headers {
+		if !redactor.headers[http.CanonicalHeaderKey(name)] {
+			redacted[name] = values
+			continue
+		}
+		redacted[name] = make([]string, len(values))
+		for i := range values {
+			redacted[name][i] = Redacted
+		}
+	}
+	return redacted
+}
+
+// URL returns rawURL with the password of its user and the values of the redacted query parameters hidden.
+// The other parameters are kept as they are written, a url that can't be parsed is returned as is.
+func (redactor *Redactor) URL(rawURL string) string {
+	if redactor == nil || rawURL == "" {
+		return rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	_, hasPassword := parsed.User.Password()
+	if hasPassword {
+		parsed.User = url.UserPassword(parsed.User.Username(), Redacted)
+	}
+	var redactedQuery bool
+	if parsed.RawQuery != "" {
+		params := strings.Split(parsed.RawQuery, "&")
+		for i, param := range params {
+			key, _, _ := strings.Cut(param, "=")
+			name, err := url.QueryUnescape(key)
+			if err != nil {
+				name = key
+			}
+			if redactor.queries[strings.ToLower(name)] {
+				params[i] = key + "=" + Redacted
+				redactedQuery = true
+			}
+		}
+		parsed.RawQuery = strings.Join(params, "&")
+	}
+	if !hasPassword && !redactedQuery {
+		return rawURL
+	}
+
+	redacted := parsed.String()
+	if hasPassword {
+		// The user info is escaped, the placeholder is shown as is like in the headers and the bodies
+		redacted = strings.Replace(redacted, url.PathEscape(Redacted), Redacted, 1)
+	}
+	return redacted
+}
+
+// redactedError is an error whose text has its secrets hidden, it still wraps the original error
+type redactedError struct {
+	text string
+	err  error
+}
+
+func (err redactedError) Error() string {
+	return err.text
+}
+
+func (err redactedError) Unwrap() error {
+	return err.err
+}
+
+// Error returns err with the url of the request it wraps redacted, as net/http writes the whole url in its errors
+func (redactor *Redactor) Error(err error) error {
+	var urlErr *url.Error
+	if redactor == nil || !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted := redactor.URL(urlErr.URL)
+	if redacted == urlErr.URL {
+		return err
+	}
+	return redactedError{text: strings.ReplaceAll(err.Error(), urlErr.URL, redacted), err: err}
+}
+
+// Body returns body with the redacted fields hidden if it's JSON, as is otherwise.
+// The values are replaced in place, so that the body keeps its formatting.
+func (redactor *Redactor) Body(body string) string {
+	if redactor == nil || !gjson.Valid(body) {
+		return body
+	}
+
+	var spans [][2]int
+	redactor.collect(gjson.Parse(body), nil, &spans)
+	if len(spans) == 0 {
+		return body
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var builder strings.Builder
+	var last int
+	for _, span := range spans {
+		builder.WriteString(body[last:span[0]])
+		builder.WriteString(strconv.Quote(Redacted))
+		last = span[1]
+	}
+	builder.WriteString(body[last:])
+	return builder.String()
+}
+
+// collect adds the position of the redacted values below value, at path, to spans
+func (redactor *Redactor) collect(value gjson.Result, path []string, spans *[][2]int) {
+	if !value.IsObject() && !value.IsArray() {
+		return
+	}
+
+	value.ForEach(func(key, child gjson.Result) bool {
+		name := key.String()
+		childPath := append(path[:len(path):len(path)], name)
+		if value.IsObject() && strings.Contains(strings.ToLower(name), "password") || redactor.matchesPath(childPath) {
+			*spans = append(*spans, [2]int{child.Index, child.Index + len(child.Raw)})
+		} else {
+			redactor.collect(child, childPath, spans)
+		}
+		return true
+	})
+}
+
+func (redactor *Redactor) matchesPath(path []string) bool {
+	for _, rule := range redactor.jsonPaths {
+		if len(rule) != len(path) {
+			continue
+		}
+		matches := true
+		for i := range rule {
+			if rule[i] != "*" && rule[i] != path[i] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// Results returns a copy of results with its urls, its headers and its body redacted
+func (redactor *Redactor) Results(results Results) Results {
+	if redactor == nil {
+		return results
+	}
+
+	results.URL = redactor.URL(results.URL)
+	if results.Redirects != nil {
+		redirects := make([]Redirect, len(results.Redirects))
+		for i, redirect := range results.Redirects {
+			redirect.URL = redactor.URL(redirect.URL)
+			redirect.Location = redactor.URL(redirect.Location)
+			redirects[i] = redirect
+		}
+		results.Redirects = redirects
+	}
+	if results.Attempts != nil {
+		attempts := make([]Attempt, len(results.Attempts))
+		for i, attempt := range results.Attempts {
+			attempt.Err = redactor.Error(attempt.Err)
+			attempts[i] = attempt
+		}
+		results.Attempts = attempts
+	}
+	results.RequestHeaders = redactor.Headers(results.RequestHeaders)
+	results.Headers = redactor.Headers(results.Headers)
+	results.StrBody = redactor.Body(results.StrBody)
+	return results
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// DefaultMaxRedirects is the number of redirects followed when RedirectPolicy.Max is 0
+const DefaultMaxRedirects = 10
+
+// RedirectPolicy tells how redirects are followed
+type RedirectPolicy struct {
+	NoFollow bool
+	// Max is the maximum number of redirects followed, DefaultMaxRedirects if 0
+	Max int
+	// KeepMethod re-sends the method and the body on 301 and 302 instead of switching to GET.
+	// They are always re-sent on 307 and 308 and never on 303.
+	KeepMethod bool
+}
+
+// Redirect records a hop of a redirect chain
+type Redirect struct {
+	URL        string
+	StatusCode int
+	Status     string
+	Location   string
+	RespTime   int64
+}
+
+func (redirect Redirect) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		URL        string `json:"url"`
+		Status     string `json:"status"`
+		StatusCode int    `json:"status-code"`
+		Location   string `json:"location"`
+		Time       string `json:"time"`
+	}{
+		URL:        redirect.URL,
+		Status:     redirect.Status,
+		StatusCode: redirect.StatusCode,
+		Location:   redirect.Location,
+		Time:       strconv.FormatInt(redirect.RespTime, 10) + " ms",
+	})
+}
+
+// IsRedirect reports whether statusCode is a redirect that can be followed
+func IsRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// RedirectMethod returns the method used to follow a redirect with statusCode
+// and whether the body must be sent again
+func (policy RedirectPolicy) RedirectMethod(method string, statusCode int) (string, bool) {
+	switch statusCode {
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return method, true
+	case http.StatusMovedPermanently, http.StatusFound:
+		if policy.KeepMethod || method == http.MethodGet || method == http.MethodHead {
+			return method, policy.KeepMethod
+		}
+	case http.StatusSeeOther:
+		if method == http.MethodHead {
+			return method, false
+		}
+	}
+	return http.MethodGet, false
+}
+
+// MaxRedirects returns the maximum number of redirects to follow
+func (policy RedirectPolicy) MaxRedirects() int {
+	if policy.NoFollow {
+		return 0
+	}
+	if policy.Max <= 0 {
+		return DefaultMaxRedirects
+	}
+	return policy.Max
+}
+
+// sensitiveHeaders are not forwarded to a different host
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Cookie2", "Www-Authenticate"}
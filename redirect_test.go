@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectMethod(t *testing.T) {
+	tests := []struct {
+		method     string
+		statusCode int
+		keepMethod bool
+		wantMethod string
+		wantBody   bool
+	}{
+		{POST, http.StatusMovedPermanently, false, GET, false},
+		{POST, http.StatusFound, false, GET, false},
+		{POST, http.StatusFound, true, POST, true},
+		{POST, http.StatusSeeOther, true, GET, false},
+		{HEAD, http.StatusSeeOther, false, HEAD, false},
+		{PUT, http.StatusTemporaryRedirect, false, PUT, true},
+		{PATCH, http.StatusPermanentRedirect, false, PATCH, true},
+		{GET, http.StatusMovedPermanently, false, GET, false},
+	}
+
+	for _, test := range tests {
+		policy := RedirectPolicy{KeepMethod: test.keepMethod}
+
+		method, withBody := policy.RedirectMethod(test.method, test.statusCode)
+
+		assert.Equal(t, test.wantMethod, method, test)
+		assert.Equal(t, test.wantBody, withBody, test)
+	}
+}
+
+func TestRequestFollowsRedirects(t *testing.T) {
+	server := newTestServer(t)
+
+	results, err := doRequest(GET, server.URL+"/redirect/3", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, results.StatusCode)
+	assert.Equal(t, server.URL+"/anything", results.URL)
+	assert.Len(t, results.Redirects, 3)
+	assert.Equal(t, server.URL+"/redirect/3", results.Redirects[0].URL)
+	assert.Equal(t, "/redirect/2", results.Redirects[0].Location)
+	assert.Equal(t, http.StatusFound, results.Redirects[0].StatusCode)
+}
+
+func TestRequestNoFollow(t *testing.T) {
+	server := newTestServer(t)
+
+	spec, err := NewRequestSpec(GET, server.URL+"/redirect/3", nil, JSONMode, nil)
+	assert.NoError(t, err)
+	spec.Options.Redirect.NoFollow = true
+
+	results, err := Do(context.Background(), spec)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusFound, results.StatusCode)
+	assert.Equal(t, "/redirect/2", results.Headers.Get("Location"))
+	assert.Empty(t, results.Redirects)
+}
+
+func TestRequestTooManyRedirects(t *testing.T) {
+	server := newTestServer(t)
+
+	spec, err := NewRequestSpec(GET, server.URL+"/redirect/3", nil, JSONMode, nil)
+	assert.NoError(t, err)
+	spec.Options.Redirect.Max = 2
+
+	_, err = Do(context.Background(), spec)
+
+	assert.ErrorIs(t, err, tooManyRedirectsErrMsg)
+}
+
+func TestRequestRedirectMethod(t *testing.T) {
+	server := newTestServer(t)
+	redirectUrl := func(statusCode string) string {
+		return server.URL + "/redirect-to?" + url.Values{"url": {"/anything"}, "status_code": {statusCode}}.Encode()
+	}
+
+	// 302 switches to GET and drops the body
+	results, err := doRequest(POST, redirectUrl("302"), []string{"foo=bar"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, GET, echoed(t, results)["method"])
+	assert.Equal(t, "", echoed(t, results)["data"])
+	assert.Empty(t, results.RequestHeaders.Get("Content-Type"))
+
+	// A request without body keeps an explicit Content-Type
+	results, err = doRequest(GET, redirectUrl("302"), []string{"Content-Type:application/json"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", results.RequestHeaders.Get("Content-Type"))
+
+	// 307 re-sends the method and the body
+	results, err = doRequest(POST, redirectUrl("307"), []string{"foo=bar"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, POST, echoed(t, results)["method"])
+	assert.Equal(t, map[string]any{"foo": "bar"}, echoed(t, results)["json"])
+}
+
+func TestRequestRedirectStreamedBody(t *testing.T) {
+	server := newTestServer(t)
+	redirectUrl := func(statusCode string) string {
+		return server.URL + "/redirect-to?" + url.Values{"url": {"/anything"}, "status_code": {statusCode}}.Encode()
+	}
+	streamedSpec := func(requestUrl string) RequestSpec {
+		body := &RawBody{Body: ReaderBody(strings.NewReader(`{"foo": "bar"}`)), ContentLength: -1, ContentType: "application/json"}
+		spec, err := NewRequestSpec(POST, requestUrl, nil, JSONMode, body)
+		assert.NoError(t, err)
+		return spec
+	}
+
+	// The 307 response is shown as is
+	spec := streamedSpec(redirectUrl("307"))
+	spec.Options.Redirect.NoFollow = true
+	results, err := Do(context.Background(), spec)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTemporaryRedirect, results.StatusCode)
+
+	// The body can't be sent again to follow a 307
+	_, err = Do(context.Background(), streamedSpec(redirectUrl("307")))
+
+	assert.ErrorIs(t, err, bodyConsumedErrMsg)
+
+	// 303 switches to GET, the body isn't needed again
+	results, err = Do(context.Background(), streamedSpec(redirectUrl("303")))
+
+	assert.NoError(t, err)
+	assert.Equal(t, GET, echoed(t, results)["method"])
+
+	// A multipart body has no length either but is built again to follow a 307
+	spec, err = NewRequestSpec(POST, redirectUrl("307"), []string{"foo=bar"}, MultipartMode, nil)
+	assert.NoError(t, err)
+	results, err = Do(context.Background(), spec)
+
+	assert.NoError(t, err)
+	assert.Equal(t, POST, echoed(t, results)["method"])
+	assert.Equal(t, map[string]any{"foo": []any{"bar"}}, echoed(t, results)["form"])
+}
+
+func TestRequestRedirectToOtherHost(t *testing.T) {
+	server := newTestServer(t)
+	other := newTestServer(t)
+	redirectUrl := server.URL + "/redirect-to?" + url.Values{"url": {other.URL + "/anything"}}.Encode()
+
+	results, err := doRequest(GET, redirectUrl, []string{"Authorization:Bearer token", "X-Trace:1"})
+
+	assert.NoError(t, err)
+	headers := echoed(t, results)["headers"].(map[string]any)
+	assert.NotContains(t, headers, "Authorization")
+	assert.Equal(t, []any{"1"}, headers["X-Trace"])
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/tidwall/gjson"
+)
+
+// ReplayOptions holds the settings of a replay of recorded requests
+type ReplayOptions struct {
+	// BaseURL replaces the scheme, the host and the path prefix of the recorded urls, they are kept if empty
+	BaseURL string
+	// Speed divides the recorded delays between the requests, they are sent back to back if 0
+	Speed float64
+	// History records the replayed requests, none if nil
+	History  *History
+	Redactor *Redactor
+}
+
+// ReplayResult is a recorded request and the response to it once sent again
+type ReplayResult struct {
+	Recorded HistoryEntry
+	Response Response
+}
+
+// StatusChanged tells whether the request got another status code than when it was recorded, or failed differently
+func (result ReplayResult) StatusChanged() bool {
+	if result.Response.Err != nil || result.Recorded.Error != "" {
+		return (result.Response.Err != nil) != (result.Recorded.Error != "")
+	}
+	return result.Response.Results.StatusCode != result.Recorded.StatusCode
+}
+
+// ReadRecordedRequests reads the requests of history files, log files or logs dirs, sorted by start time.
+// The log files only record the method and the url, not the headers and the body.
+func ReadRecordedRequests(paths []string) ([]HistoryEntry, error) {
+	files, err := logFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []HistoryEntry
+	for _, file := range files {
+		err := scanLogFile(file, func(data string, where string) error {
+			entry, err := parseRecordedRequest(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", where, err)
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, noLogRecordsErrMsg
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].StartTime.Before(entries[j].StartTime) })
+	for i := range entries {
+		entries[i].ID = i + 1
+	}
+	return entries, nil
+}
+
+// parseRecordedRequest parses a history record, or a GenLog record as a history record without request headers
+func parseRecordedRequest(data string) (HistoryEntry, error) {
+	if gjson.Get(data, "method").Exists() {
+		var entry HistoryEntry
+		err := json.Unmarshal([]byte(data), &entry)
+		return entry, err
+	}
+
+	record, err := ParseLogRecord(data)
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	sample := record.Sample
+	return HistoryEntry{
+		StartTime:    sample.StartTime,
+		Method:       gjson.Get(data, "request-type").String(),
+		URL:          gjson.Get(data, "url").String(),
+		DurationMs:   milliseconds(sample.Timings.Total + sample.Lag),
+		Status:       gjson.Get(data, "status").String(),
+		StatusCode:   sample.StatusCode,
+		ResponseSize: record.BodySize,
+		Timings:      &sample.Timings,
+	}, nil
+}
+
+// RebaseURL moves the recorded url to base: the scheme, the user and the host of base replace the recorded ones
+// and its path is prepended to the recorded path. The query and the fragment are kept.
+func RebaseURL(recorded string, base string) (string, error) {
+	if base == "" {
+		return recorded, nil
+	}
+
+	recordedURL, err := url.Parse(recorded)
+	if err != nil {
+		return "", err
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	if baseURL.Scheme == "" || baseURL.Host == "" {
+		return "", fmt.Errorf("%w: %s", invalidBaseURLErrMsg, base)
+	}
+
+	recordedURL.Scheme = baseURL.Scheme
+	recordedURL.User = baseURL.User
+	recordedURL.Host = baseURL.Host
+	// The escaped path keeps the escaped slashes, e.g. %2F
+	recordedURL.RawPath = strings.TrimSuffix(baseURL.EscapedPath(), "/") + recordedURL.EscapedPath()
+	recordedURL.Path = strings.TrimSuffix(baseURL.Path, "/") + recordedURL.Path
+	return recordedURL.String(), nil
+}
+
+// Replay sends the recorded requests again, one after the other, and passes every response to handle.
+// With a speed the requests are sent as far apart as they were recorded, divided by the speed.
+// If ctx is cancelled the replay stops.
+func Replay(ctx context.Context, entries []HistoryEntry, requestOptions RequestOptions, options ReplayOptions, handle func(ReplayResult)) {
+	var historyFailed bool
+
+	start := time.Now()
+	for i, entry := range entries {
+		if options.Speed > 0 {
+			offset := time.Duration(float64(entry.StartTime.Sub(entries[0].StartTime)) / options.Speed)
+			timer := time.NewTimer(time.Until(start.Add(offset)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		response := Response{Index: i + 1}
+		spec, err := entry.Spec(nil, JSONMode, nil)
+		if err == nil {
+			spec.URL, err = RebaseURL(spec.URL, options.BaseURL)
+		}
+		if err == nil {
+			spec.Options = requestOptions
+			response.Results, response.Err = Do(ctx, spec)
+		} else {
+			response.Err = err
+		}
+		if response.Err != nil && ctx.Err() != nil {
+			return
+		}
+
+		// A history that can't be written is reported once and doesn't stop the replay
+		if options.History != nil && !historyFailed {
+			if err := options.History.Append(NewHistoryEntry(spec, response, options.Redactor)); err != nil {
+				historyFailed = true
+				fmt.Printf("\nplease: history error: %v\n", err)
+			}
+		}
+
+		handle(ReplayResult{Recorded: entry, Response: response})
+	}
+}
+
+// PrintReplayResult prints the status and the response time of a replayed request next to the recorded ones
+func PrintReplayResult(result ReplayResult) {
+	recorded := result.Recorded
+	// A failed request has no final url
+	requestURL := result.Response.Results.URL
+	if result.Response.Err != nil {
+		requestURL = recorded.URL
+	}
+	line := "- Request " + strconv.Itoa(result.Response.Index) + ": " + pterm.Cyan(recorded.Method) + " " +
+		pterm.LightBlue(requestURL) + " "
+
+	recordedStatus := strconv.Itoa(recorded.StatusCode)
+	if recorded.Error != "" {
+		recordedStatus = "error"
+	}
+
+	var status string
+	switch {
+	case result.Response.Err != nil:
+		status = pterm.Red(result.Response.Err)
+	case result.StatusChanged():
+		status = pterm.Red(result.Response.Results.StatusCode)
+	default:
+		status = pterm.Green(result.Response.Results.StatusCode)
+	}
+	line += status + " (recorded " + recordedStatus + ")"
+
+	if result.Response.Err == nil && recorded.Error == "" {
+		respTime := milliseconds(result.Response.Results.Timings.Total)
+		delta := Delta(recorded.DurationMs, respTime)
+		formatted := strconv.FormatFloat(delta, 'f', 1, 64) + "%"
+		if delta > 0 {
+			formatted = "+" + formatted
+		}
+		line += ", " + pterm.Green(formatMilliseconds(respTime)) + " (recorded " + formatMilliseconds(recorded.DurationMs) +
+			", " + formatted + ")"
+	}
+	pterm.Println(line)
+}
+
+// ReplayReport compares the replayed requests to the recording
+type ReplayReport struct {
+	Recording Run
+	Replay    Run
+	// StatusChanges counts the requests that got another status code than when they were recorded
+	StatusChanges int
+}
+
+// NewReplayReport returns the report of the results of a replay that lasted elapsed
+func NewReplayReport(results []ReplayResult, elapsed time.Duration) ReplayReport {
+	report := ReplayReport{
+		Recording: Run{Name: "recording"},
+		Replay:    Run{Name: "replay"},
+	}
+
+	recorded, replayed := NewRecorder(), NewRecorder()
+	var start, end time.Time
+	for i, result := range results {
+		entry := result.Recorded
+		if i == 0 {
+			start, end = entry.StartTime, entry.StartTime
+		}
+
+		if entry.Error != "" {
+			recorded.Record(Response{Err: errors.New(entry.Error)})
+		} else {
+			respTime := time.Duration(entry.DurationMs * float64(time.Millisecond))
+			sample := Sample{Index: i + 1, StartTime: entry.StartTime, StatusCode: entry.StatusCode, RespTime: respTime.Milliseconds()}
+			sample.Timings.Total = respTime
+			recorded.RecordSample(sample, int64(entry.ResponseSize))
+			if done := entry.StartTime.Add(respTime); done.After(end) {
+				end = done
+			}
+		}
+
+		replayed.Record(result.Response)
+		if result.StatusChanged() {
+			report.StatusChanges++
+		}
+	}
+
+	report.Recording.Summary = recorded.Summary(end.Sub(start))
+	report.Replay.Summary = replayed.Summary(elapsed)
+	return report
+}
+
+// PrintReplayReport prints the number of changed status codes and compares the statistics of the replay to the recording
+func PrintReplayReport(report ReplayReport) {
+	changes := pterm.Green(report.StatusChanges)
+	if report.StatusChanges > 0 {
+		changes = pterm.Red(report.StatusChanges)
+	}
+	pterm.Println("\n- Status code changes: " + changes + "/" + strconv.Itoa(report.Replay.Summary.Requests))
+
+	PrintComparison([]Run{report.Recording, report.Replay})
+}
@@ -1,208 +1,172 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
 	"time"
 )
 
 const (
-	ContentType = "application/json"
+	ContentType     = "application/json"
+	AcceptJSON      = "application/json, */*;q=0.5"
+	FormContentType = "application/x-www-form-urlencoded; charset=utf-8"
 )
 
-func GetRequest(requestUrl string) (Results, error) {
-	var results Results
+// RequestSpec describes an http request independently of its method
+type RequestSpec struct {
+	Method  string
+	URL     string
+	Headers http.Header
+	// Body is nil if the request has no body
+	Body Body
+	// ContentLength is the length of the body, -1 if unknown
+	ContentLength int64
+	Options       RequestOptions
+}
 
-	results.StartTime = time.Now()
-	resp, err := http.Get(requestUrl)
-	results.RespTime = time.Since(results.StartTime).Milliseconds()
+// RequestOptions holds the settings that control how a RequestSpec is sent
+type RequestOptions struct {
+	// Client used to send the request, defaultClient if nil.
+	// It mustn't follow redirects, see NewClient.
+	Client *http.Client
+	// Timeout limits the whole request, response body included
+	Timeout time.Duration
+	// ReadTimeout limits the time spent waiting for the server between two reads
+	ReadTimeout time.Duration
+	Retry       RetryPolicy
+	Redirect    RedirectPolicy
+}
 
-	if err != nil {
-		return Results{}, err
-	}
+// defaultClient is shared by every request so that connections are reused
+var defaultClient = NewClient(ClientOptions{})
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return Results{}, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(GenericError, err)
-		}
-	}(resp.Body)
+// Do sends the request described by spec and collects the response.
+// Failed attempts are retried according to the retry policy of spec.
+func Do(ctx context.Context, spec RequestSpec) (Results, error) {
+	var attempts []Attempt
+	var delay time.Duration
 
-	results.StrBody = string(body)
-	results.StatusCode = resp.StatusCode
-	results.Status = resp.Status
-	results.Headers = resp.Header
-	results.Protocol = resp.Proto
+	for number := 1; ; number++ {
+		results, err := send(ctx, spec)
 
-	return results, nil
-}
+		attempt := Attempt{Number: number, Delay: delay, StatusCode: results.StatusCode, RespTime: results.RespTime, Err: err}
+		attempts = append(attempts, attempt)
 
-func PostRequest(requestUrl string, keysValues []string) (Results, error) {
-	var results Results
-	jsonMap := make(map[string]string)
-
-	var splitValue []string
-	for _, value := range keysValues {
-		if value != "" {
-			if strings.Contains(value, "=") {
-				splitValue = strings.Split(value, "=")
-				jsonMap[splitValue[0]] = splitValue[1]
-			} else {
-				return Results{}, invalidSyntaxErrMsg
-			}
+		retry := spec.Options.Retry
+		if number > retry.Retries || ctx.Err() != nil || !retry.On.Match(results, err) {
+			results.Attempts = attempts
+			return results, err
 		}
-	}
-
-	jsonBody, err := json.Marshal(jsonMap)
-	if err != nil {
-		return Results{}, err
-	}
-	payload := bytes.NewBuffer(jsonBody)
-
-	results.StartTime = time.Now()
-	resp, err := http.Post(requestUrl, ContentType, payload)
-	results.RespTime = time.Since(results.StartTime).Milliseconds()
-	if err != nil {
-		return Results{}, err
-	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return Results{}, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(GenericError, err)
+		delay = retry.Backoff(number, results.Headers)
+		if err := sleep(ctx, delay); err != nil {
+			return Results{}, err
 		}
-	}(resp.Body)
-
-	results.StrBody = string(body)
-	results.StatusCode = resp.StatusCode
-	results.Status = resp.Status
-	results.Headers = resp.Header
-	results.Protocol = resp.Proto
-
-	return results, nil
+	}
 }
 
-func PutRequest(requestUrl string, keysValues []string) (Results, error) {
+// send sends the request described by spec once.
+// The request is aborted when ctx is done or one of the timeouts expires.
+func send(ctx context.Context, spec RequestSpec) (Results, error) {
 	var results Results
-	jsonMap := make(map[string]string)
-
-	var splitValue []string
-	for _, value := range keysValues {
-		if value != "" {
-			if strings.Contains(value, "=") {
-				splitValue = strings.Split(value, "=")
-				jsonMap[splitValue[0]] = splitValue[1]
-			} else {
-				return Results{}, invalidSyntaxErrMsg
-			}
-		}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if spec.Options.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		timeoutErr := fmt.Errorf("%w after %v", timeoutErrMsg, spec.Options.Timeout)
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, spec.Options.Timeout, timeoutErr)
+		defer cancelTimeout()
 	}
 
-	jsonBody, err := json.Marshal(jsonMap)
-	if err != nil {
-		return Results{}, err
+	var readTimer *time.Timer
+	if readTimeout := spec.Options.ReadTimeout; readTimeout > 0 {
+		readTimeoutErr := fmt.Errorf("%w after %v", readTimeoutErrMsg, readTimeout)
+		readTimer = time.AfterFunc(readTimeout, func() {
+			cancel(readTimeoutErr)
+		})
+		// Waiting for the response starts once the request has been written
+		readTimer.Stop()
+		defer readTimer.Stop()
+
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			WroteRequest: func(httptrace.WroteRequestInfo) {
+				readTimer.Reset(readTimeout)
+			},
+		})
 	}
-	payload := bytes.NewBuffer(jsonBody)
 
-	// Define the PUT request and set any additional headers
-	req, err := http.NewRequest(http.MethodPut, requestUrl, payload)
-	req.Header.Set("Content-Type", ContentType)
-	if err != nil {
-		return Results{}, err
+	client := spec.Options.Client
+	if client == nil {
+		client = defaultClient
 	}
 
-	// Instantiate the http Client
-	client := &http.Client{}
+	var tracer timingTracer
+	ctx = httptrace.WithClientTrace(ctx, tracer.Trace())
 
-	results.StartTime = time.Now()
-	// Perform the PUT request
-	resp, err := client.Do(req)
-	results.RespTime = time.Since(results.StartTime).Milliseconds()
-	if err != nil {
-		return Results{}, err
-	}
+	redirects := spec.Options.Redirect
+	method := spec.Method
+	requestUrl := spec.URL
+	withBody := spec.Body != nil
+	sameHost := true
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+	results.StartTime = time.Now()
+	var req *http.Request
+	var resp *http.Response
+	for {
+		var err error
+		req, err = newRequest(ctx, spec, method, requestUrl, withBody, sameHost)
 		if err != nil {
-			log.Fatalln(GenericError, err)
+			return Results{}, err
 		}
-	}(resp.Body)
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return Results{}, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+		tracer.Reset()
+		hopStartTime := time.Now()
+		// Perform the request
+		resp, err = client.Do(req)
 		if err != nil {
-			log.Fatalln(GenericError, err)
+			return Results{}, contextError(ctx, err)
 		}
-	}(resp.Body)
-
-	results.StrBody = string(body)
-	results.StatusCode = resp.StatusCode
-	results.Status = resp.Status
-	results.Headers = resp.Header
-	results.Protocol = resp.Proto
 
-	return results, nil
-}
-
-func PatchRequest(requestUrl string, keysValues []string) (Results, error) {
-	var results Results
-	jsonMap := make(map[string]string)
-
-	var splitValue []string
-	for _, value := range keysValues {
-		if value != "" {
-			if strings.Contains(value, "=") {
-				splitValue = strings.Split(value, "=")
-				jsonMap[splitValue[0]] = splitValue[1]
-			} else {
-				return Results{}, invalidSyntaxErrMsg
-			}
+		location := resp.Header.Get("Location")
+		if redirects.NoFollow || !IsRedirect(resp.StatusCode) || location == "" {
+			break
+		}
+		if len(results.Redirects) >= redirects.MaxRedirects() {
+			_ = resp.Body.Close()
+			return Results{}, fmt.Errorf("%w: stopped after %d redirects", tooManyRedirectsErrMsg, len(results.Redirects))
 		}
-	}
-
-	jsonBody, err := json.Marshal(jsonMap)
-	if err != nil {
-		return Results{}, err
-	}
-
-	payload := bytes.NewBuffer(jsonBody)
-
-	// Define the PATCH request and set any additional headers
-	req, err := http.NewRequest(http.MethodPatch, requestUrl, payload)
-	req.Header.Set("Content-Type", ContentType)
-	if err != nil {
-		return Results{}, err
-	}
 
-	// Instantiate the http Client
-	client := &http.Client{}
+		nextUrl, err := req.URL.Parse(location)
+		if err != nil {
+			_ = resp.Body.Close()
+			return Results{}, err
+		}
 
-	results.StartTime = time.Now()
-	// Perform the PATCH request
-	resp, err := client.Do(req)
-	results.RespTime = time.Since(results.StartTime).Milliseconds()
-	if err != nil {
-		return Results{}, err
+		results.Redirects = append(results.Redirects, Redirect{
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Location:   location,
+			RespTime:   time.Since(hopStartTime).Milliseconds(),
+		})
+
+		// Drain the body so that the connection can be reused
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		_ = resp.Body.Close()
+
+		var keepBody bool
+		method, keepBody = redirects.RedirectMethod(method, resp.StatusCode)
+		withBody = withBody && keepBody
+		sameHost = sameHost && nextUrl.Host == req.URL.Host
+		requestUrl = nextUrl.String()
 	}
-
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
@@ -211,17 +175,19 @@ func PatchRequest(requestUrl string, keysValues []string) (Results, error) {
 	}(resp.Body)
 
 	// Read response
-	body, err := io.ReadAll(resp.Body)
+	var bodyReader io.Reader = resp.Body
+	if readTimer != nil {
+		bodyReader = &timeoutReader{reader: resp.Body, timer: readTimer, timeout: spec.Options.ReadTimeout}
+	}
+	body, err := io.ReadAll(bodyReader)
 	if err != nil {
-		return Results{}, err
+		return Results{}, contextError(ctx, err)
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(GenericError, err)
-		}
-	}(resp.Body)
+	results.Timings = tracer.Timings(results.StartTime, time.Now())
+	results.RespTime = results.Timings.Total.Milliseconds()
 
+	results.URL = req.URL.String()
+	results.RequestHeaders = req.Header
 	results.StrBody = string(body)
 	results.StatusCode = resp.StatusCode
 	results.Status = resp.Status
@@ -231,144 +197,144 @@ func PatchRequest(requestUrl string, keysValues []string) (Results, error) {
 	return results, nil
 }
 
-func DeleteRequest(requestUrl string) (Results, error) {
-	var results Results
-
-	// Define the DELETE request and set any additional headers
-	req, err := http.NewRequest(http.MethodDelete, requestUrl, nil)
-	if err != nil {
-		return Results{}, err
-	}
-
-	// Instantiate the http Client
-	client := &http.Client{}
-
-	results.StartTime = time.Now()
-	// Perform the DELETE request
-	resp, err := client.Do(req)
-	results.RespTime = time.Since(results.StartTime).Milliseconds()
+// newRequest builds the http request sent to requestUrl with method.
+// The body of spec is sent only if withBody is set, the credentials only if sameHost is set.
+func newRequest(ctx context.Context, spec RequestSpec, method string, requestUrl string, withBody bool, sameHost bool) (*http.Request, error) {
+	// Define the request and set any additional headers
+	req, err := http.NewRequestWithContext(ctx, method, requestUrl, nil)
 	if err != nil {
-		return Results{}, err
+		return nil, err
 	}
-
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+	if withBody {
+		// The body is streamed from its source instead of being buffered
+		req.Body, err = spec.Body()
 		if err != nil {
-			log.Fatalln(GenericError, err)
+			return nil, err
 		}
-	}(resp.Body)
+		// net/http opens the body again for a 307 or 308 response even when it isn't followed,
+		// a body of unknown length may be streamed from stdin and can't be. The redirects are followed
+		// by Do, which opens it again itself.
+		if spec.ContentLength >= 0 {
+			req.GetBody = spec.Body
+		}
+		req.ContentLength = spec.ContentLength
+	}
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return Results{}, err
+	for name, values := range spec.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(GenericError, err)
+	// The headers of the body are dropped with it by a 301, 302 or 303 redirect,
+	// a request sent without body keeps the ones given on the command line
+	if spec.Body != nil && !withBody {
+		req.Header.Del("Content-Type")
+		req.Header.Del("Content-Length")
+	}
+	if sameHost {
+		// net/http ignores the Host header, the request's Host field is used instead
+		if host := req.Header.Get("Host"); host != "" {
+			req.Host = host
 		}
-	}(resp.Body)
-
-	results.StrBody = string(body)
-	results.StatusCode = resp.StatusCode
-	results.Status = resp.Status
-	results.Headers = resp.Header
-	results.Protocol = resp.Proto
+	} else {
+		for _, name := range sensitiveHeaders {
+			req.Header.Del(name)
+		}
+	}
+	// An empty User-Agent stops net/http from sending its own one
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "")
+	}
 
-	return results, err
+	return req, nil
 }
 
-func HeadRequest(requestUrl string) (Results, error) {
-	var results Results
-
-	// Define the HEAD request and set any additional headers
-	req, err := http.NewRequest(http.MethodHead, requestUrl, nil)
-	if err != nil {
-		return Results{}, err
+// contextError returns the reason why ctx has been cancelled if err has been caused by it
+func contextError(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); cause != nil {
+		return cause
 	}
-	// Instantiate the http Client
-	client := &http.Client{}
+	return err
+}
 
-	results.StartTime = time.Now()
-	// Perform the HEAD request
-	resp, err := client.Do(req)
-	results.RespTime = time.Since(results.StartTime).Milliseconds()
-	if err != nil {
-		return Results{}, err
+// NewRequestSpec builds the RequestSpec for method and requestUrl from the request items.
+// Header items override the default headers, key==value items are added to the query string
+// and the body items are sent as described by mode, unless rawBody is given instead.
+func NewRequestSpec(method string, requestUrl string, args []string, mode BodyMode, rawBody *RawBody) (RequestSpec, error) {
+	spec := RequestSpec{
+		Method:  strings.ToUpper(method),
+		URL:     requestUrl,
+		Headers: DefaultHeaders(),
 	}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(GenericError, err)
-		}
-	}(resp.Body)
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	items, err := ParseItems(args)
 	if err != nil {
-		return Results{}, err
+		return RequestSpec{}, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatalln(GenericError, err)
-		}
-	}(resp.Body)
-
-	results.StrBody = string(body)
-	results.StatusCode = resp.StatusCode
-	results.Status = resp.Status
-	results.Headers = resp.Header
-	results.Protocol = resp.Proto
-
-	return results, nil
-}
-
-func OptionsRequest(requestUrl string) (Results, error) {
-	var results Results
 
-	// Define the OPTIONS request and set any additional headers
-	req, err := http.NewRequest(http.MethodOptions, requestUrl, nil)
+	spec.URL, err = AddQuery(requestUrl, items.Query)
 	if err != nil {
-		return Results{}, err
+		return RequestSpec{}, err
 	}
-	// Instantiate the http Client
-	client := &http.Client{}
 
-	results.StartTime = time.Now()
-	// Perform the OPTIONS request
-	resp, err := client.Do(req)
-	results.RespTime = time.Since(results.StartTime).Milliseconds()
-	if err != nil {
-		return Results{}, err
+	hasItems := len(items.Data) > 0 || len(items.Files) > 0
+	// Files can only be uploaded in a multipart body
+	if mode == FormMode && len(items.Files) > 0 {
+		mode = MultipartMode
 	}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+	switch {
+	case rawBody != nil:
+		if hasItems {
+			return RequestSpec{}, manyBodiesErrMsg
+		}
+		spec.SetBody(rawBody.Body, rawBody.ContentLength, rawBody.ContentType)
+	case !hasItems:
+	case mode == MultipartMode:
+		body, contentType, err := MultipartBody(items.Data, items.Files)
 		if err != nil {
-			log.Fatalln(GenericError, err)
+			return RequestSpec{}, err
 		}
-	}(resp.Body)
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return Results{}, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+		spec.SetBody(body, -1, contentType)
+	case mode == FormMode:
+		formBody, err := FormBody(items.Data)
 		if err != nil {
-			log.Fatalln(GenericError, err)
+			return RequestSpec{}, err
 		}
-	}(resp.Body)
+		spec.SetBody(BytesBody(formBody), int64(len(formBody)), FormContentType)
+	default:
+		if len(items.Files) > 0 {
+			return RequestSpec{}, fileFieldsErrMsg
+		}
+		jsonBody, err := JSONBody(items.Data)
+		if err != nil {
+			return RequestSpec{}, err
+		}
+		spec.SetBody(BytesBody(jsonBody), int64(len(jsonBody)), ContentType)
+		spec.Headers.Set("Accept", AcceptJSON)
+	}
 
-	results.StrBody = string(body)
-	results.StatusCode = resp.StatusCode
-	results.Status = resp.Status
-	results.Headers = resp.Header
-	results.Protocol = resp.Proto
+	for name, values := range items.Headers {
+		spec.Headers[name] = values
+	}
+	for _, name := range items.RemovedHeaders {
+		spec.Headers.Del(name)
+	}
 
-	return results, nil
+	return spec, nil
+}
+
+// SetBody replaces the body of the request and its Content-Type
+func (spec *RequestSpec) SetBody(body Body, contentLength int64, contentType string) {
+	spec.Body = body
+	spec.ContentLength = contentLength
+	spec.Headers.Set("Content-Type", contentType)
+}
+
+// DefaultHeaders returns the headers sent with every request
+func DefaultHeaders() http.Header {
+	headers := make(http.Header)
+	headers.Set("User-Agent", "please/"+Version)
+	headers.Set("Accept", "*/*")
+	return headers
 }
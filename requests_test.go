@@ -1,16 +1,182 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestServer starts an httpbin-like server that echoes the received request
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	var flakyCalls atomic.Int64
+
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		form := make(map[string]any)
+		files := make(map[string]any)
+		var body []byte
+
+		switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+		case "application/x-www-form-urlencoded":
+			_ = r.ParseForm()
+			for key, values := range r.PostForm {
+				form[key] = values
+			}
+		case "multipart/form-data":
+			_ = r.ParseMultipartForm(1 << 20)
+			for key, values := range r.MultipartForm.Value {
+				form[key] = values
+			}
+			for key, headers := range r.MultipartForm.File {
+				f, _ := headers[0].Open()
+				content, _ := io.ReadAll(f)
+				files[key] = map[string]any{
+					"filename":     headers[0].Filename,
+					"content-type": headers[0].Header.Get("Content-Type"),
+					"content":      string(content),
+				}
+			}
+		default:
+			body, _ = io.ReadAll(r.Body)
+		}
+
+		var jsonBody any
+		_ = json.Unmarshal(body, &jsonBody)
+
+		w.Header().Set("Content-Type", ContentType)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"method":  r.Method,
+			"url":     r.URL.String(),
+			"args":    r.URL.Query(),
+			"headers": r.Header,
+			"data":    string(body),
+			"json":    jsonBody,
+			"form":    form,
+			"files":   files,
+		})
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Simulate network latency so that the response time is measurable
+		time.Sleep(2 * time.Millisecond)
+
+		switch r.URL.Path {
+		case "/":
+			if r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+				return
+			}
+			echo(w, r)
+		case "/get", "/post", "/put", "/patch", "/delete":
+			if r.URL.Path[1:] != methodPath(r.Method) {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			echo(w, r)
+		case "/anything":
+			echo(w, r)
+		case "/delay":
+			// Wait before sending the headers
+			delay, _ := time.ParseDuration(r.URL.Query().Get("duration"))
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			echo(w, r)
+		case "/redirect-to":
+			// Redirect to url with status_code, like httpbin
+			statusCode, _ := strconv.Atoi(r.URL.Query().Get("status_code"))
+			if statusCode == 0 {
+				statusCode = http.StatusFound
+			}
+			w.Header().Set("Location", r.URL.Query().Get("url"))
+			w.WriteHeader(statusCode)
+		case "/flaky":
+			// Fail the first n requests
+			failures, _ := strconv.Atoi(r.URL.Query().Get("failures"))
+			if flakyCalls.Add(1) <= int64(failures) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			echo(w, r)
+		case "/stall":
+			// Send part of the body and then stop sending
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			// Redirect n times and then echo the request, like httpbin
+			if n, found := strings.CutPrefix(r.URL.Path, "/redirect/"); found {
+				hops, _ := strconv.Atoi(n)
+				if hops <= 1 {
+					http.Redirect(w, r, "/anything", http.StatusFound)
+				} else {
+					http.Redirect(w, r, "/redirect/"+strconv.Itoa(hops-1), http.StatusFound)
+				}
+				return
+			}
+			http.NotFound(w, r)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func methodPath(method string) string {
+	switch method {
+	case GET:
+		return "get"
+	case POST:
+		return "post"
+	case PUT:
+		return "put"
+	case PATCH:
+		return "patch"
+	case DELETE:
+		return "delete"
+	}
+	return ""
+}
+
+// echoed decodes the request echoed back by the test server
+func echoed(t *testing.T, results Results) map[string]any {
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(results.StrBody), &decoded); err != nil {
+		t.Fatalf("Failed to decode echoed request: %v", err)
+	}
+	return decoded
+}
+
+func doRequest(method string, requestUrl string, keysValues []string) (Results, error) {
+	spec, err := NewRequestSpec(method, requestUrl, keysValues, JSONMode, nil)
+	if err != nil {
+		return Results{}, err
+	}
+	return Do(context.Background(), spec)
+}
+
 func TestGetRequest(t *testing.T) {
-	requestUrl := "https://httpbin.org/get"
+	requestUrl := newTestServer(t).URL + "/get"
 
-	results, err := GetRequest(requestUrl)
+	results, err := doRequest(GET, requestUrl, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -22,9 +188,9 @@ func TestGetRequest(t *testing.T) {
 }
 
 func TestGetRequestURLNotFound(t *testing.T) {
-	requestUrl := "https://httpbin.org/notfound"
+	requestUrl := newTestServer(t).URL + "/notfound"
 
-	results, err := GetRequest(requestUrl)
+	results, err := doRequest(GET, requestUrl, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -37,16 +203,16 @@ func TestGetRequestURLNotFound(t *testing.T) {
 func TestGetRequestInvalidURL(t *testing.T) {
 	requestUrl := "invalid-url"
 
-	_, err := GetRequest(requestUrl)
+	_, err := doRequest(GET, requestUrl, nil)
 
 	assert.Error(t, err)
 }
 
 func TestPostRequest(t *testing.T) {
-	requestUrl := "https://httpbin.org/post"
+	requestUrl := newTestServer(t).URL + "/post"
 	keysValues := []string{"key1=value1", "key2=value2"}
 
-	results, err := PostRequest(requestUrl, keysValues)
+	results, err := doRequest(POST, requestUrl, keysValues)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -55,13 +221,14 @@ func TestPostRequest(t *testing.T) {
 	assert.NotZero(t, results.RespTime)
 	assert.NotEmpty(t, results.StrBody)
 	assert.Equal(t, http.StatusOK, results.StatusCode)
+	assert.Equal(t, map[string]any{"key1": "value1", "key2": "value2"}, echoed(t, results)["json"])
 }
 
 func TestPostRequestWithEmptyPayload(t *testing.T) {
-	requestUrl := "https://httpbin.org/post"
+	requestUrl := newTestServer(t).URL + "/post"
 	keysValues := []string{""}
 
-	results, err := PostRequest(requestUrl, keysValues)
+	results, err := doRequest(POST, requestUrl, keysValues)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -73,10 +240,10 @@ func TestPostRequestWithEmptyPayload(t *testing.T) {
 }
 
 func TestPostRequestURLNotFound(t *testing.T) {
-	requestUrl := "https://httpbin.org/notfound"
+	requestUrl := newTestServer(t).URL + "/notfound"
 	keysValues := []string{"key1=value1", "key2=value2"}
 
-	results, err := PostRequest(requestUrl, keysValues)
+	results, err := doRequest(POST, requestUrl, keysValues)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -90,17 +257,26 @@ func TestPostRequestWithInvalidURL(t *testing.T) {
 	requestUrl := "invalid-url"
 	keysValues := []string{"key=value"}
 
-	results, err := PostRequest(requestUrl, keysValues)
+	results, err := doRequest(POST, requestUrl, keysValues)
 
 	assert.Error(t, err)
 	assert.NotEqual(t, http.StatusOK, results.StatusCode)
 }
 
+func TestPostRequestWithInvalidSyntax(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/post"
+	keysValues := []string{"key"}
+
+	_, err := doRequest(POST, requestUrl, keysValues)
+
+	assert.ErrorIs(t, err, invalidSyntaxErrMsg)
+}
+
 func TestPutRequestWithJsonPayload(t *testing.T) {
-	requestUrl := "https://httpbin.org/put"
+	requestUrl := newTestServer(t).URL + "/put"
 	keysValues := []string{"key1=value1", "key2=value2"}
 
-	results, err := PutRequest(requestUrl, keysValues)
+	results, err := doRequest(PUT, requestUrl, keysValues)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -112,10 +288,10 @@ func TestPutRequestWithJsonPayload(t *testing.T) {
 }
 
 func TestPutRequestWithEmptyPayload(t *testing.T) {
-	requestUrl := "https://httpbin.org/put"
+	requestUrl := newTestServer(t).URL + "/put"
 	keysValues := []string{""}
 
-	results, err := PutRequest(requestUrl, keysValues)
+	results, err := doRequest(PUT, requestUrl, keysValues)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -127,10 +303,10 @@ func TestPutRequestWithEmptyPayload(t *testing.T) {
 }
 
 func TestPutRequestURLNotFound(t *testing.T) {
-	requestUrl := "https://httpbin.org/notfound"
+	requestUrl := newTestServer(t).URL + "/notfound"
 	keysValues := []string{"key1=value1", "key2=value2"}
 
-	results, err := PutRequest(requestUrl, keysValues)
+	results, err := doRequest(PUT, requestUrl, keysValues)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -144,17 +320,17 @@ func TestPutRequestWithInvalidURL(t *testing.T) {
 	requestUrl := "invalid-url"
 	keysValues := []string{"key=value"}
 
-	results, err := PutRequest(requestUrl, keysValues)
+	results, err := doRequest(PUT, requestUrl, keysValues)
 
 	assert.Error(t, err)
 	assert.NotEqual(t, http.StatusOK, results.StatusCode)
 }
 
 func TestPatchRequestWithJsonPayload(t *testing.T) {
-	requestUrl := "https://httpbin.org/patch"
+	requestUrl := newTestServer(t).URL + "/patch"
 	keysValues := []string{"key1=value1", "key2=value2"}
 
-	results, err := PatchRequest(requestUrl, keysValues)
+	results, err := doRequest(PATCH, requestUrl, keysValues)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -166,10 +342,10 @@ func TestPatchRequestWithJsonPayload(t *testing.T) {
 }
 
 func TestPatchRequestWithEmptyPayload(t *testing.T) {
-	requestUrl := "https://httpbin.org/patch"
+	requestUrl := newTestServer(t).URL + "/patch"
 	keysValues := []string{""}
 
-	results, err := PatchRequest(requestUrl, keysValues)
+	results, err := doRequest(PATCH, requestUrl, keysValues)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -181,10 +357,10 @@ func TestPatchRequestWithEmptyPayload(t *testing.T) {
 }
 
 func TestPatchRequestURLNotFound(t *testing.T) {
-	requestUrl := "https://httpbin.org/notfound"
+	requestUrl := newTestServer(t).URL + "/notfound"
 	keysValues := []string{"key1=value1", "key2=value2"}
 
-	results, err := PatchRequest(requestUrl, keysValues)
+	results, err := doRequest(PATCH, requestUrl, keysValues)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -198,16 +374,16 @@ func TestPatchRequestWithInvalidURL(t *testing.T) {
 	requestUrl := "invalid-url"
 	keysValues := []string{"key=value"}
 
-	results, err := PatchRequest(requestUrl, keysValues)
+	results, err := doRequest(PATCH, requestUrl, keysValues)
 
 	assert.Error(t, err)
 	assert.NotEqual(t, http.StatusOK, results.StatusCode)
 }
 
 func TestDeleteRequest(t *testing.T) {
-	requestUrl := "https://httpbin.org/delete"
+	requestUrl := newTestServer(t).URL + "/delete"
 
-	results, err := DeleteRequest(requestUrl)
+	results, err := doRequest(DELETE, requestUrl, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -219,9 +395,9 @@ func TestDeleteRequest(t *testing.T) {
 }
 
 func TestDeleteRequestURLNotFound(t *testing.T) {
-	requestUrl := "https://httpbin.org/notfound"
+	requestUrl := newTestServer(t).URL + "/notfound"
 
-	results, err := DeleteRequest(requestUrl)
+	results, err := doRequest(DELETE, requestUrl, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -234,15 +410,15 @@ func TestDeleteRequestURLNotFound(t *testing.T) {
 func TestDeleteRequestWithInvalidURL(t *testing.T) {
 	requestUrl := "invalid-url"
 
-	_, err := DeleteRequest(requestUrl)
+	_, err := doRequest(DELETE, requestUrl, nil)
 
 	assert.Error(t, err)
 }
 
 func TestHeadRequest(t *testing.T) {
-	requestUrl := "https://httpbin.org/"
+	requestUrl := newTestServer(t).URL + "/"
 
-	results, err := HeadRequest(requestUrl)
+	results, err := doRequest(HEAD, requestUrl, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -254,9 +430,9 @@ func TestHeadRequest(t *testing.T) {
 }
 
 func TestHeadRequestURLNotFound(t *testing.T) {
-	requestUrl := "https://httpbin.org/notfound"
+	requestUrl := newTestServer(t).URL + "/notfound"
 
-	results, err := HeadRequest(requestUrl)
+	results, err := doRequest(HEAD, requestUrl, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -269,15 +445,15 @@ func TestHeadRequestURLNotFound(t *testing.T) {
 func TestHeadRequestWithInvalidURL(t *testing.T) {
 	requestUrl := "invalid-url"
 
-	_, err := HeadRequest(requestUrl)
+	_, err := doRequest(HEAD, requestUrl, nil)
 
 	assert.Error(t, err)
 }
 
 func TestOptionsRequest(t *testing.T) {
-	requestUrl := "https://httpbin.org/"
+	requestUrl := newTestServer(t).URL + "/"
 
-	results, err := OptionsRequest(requestUrl)
+	results, err := doRequest(OPTIONS, requestUrl, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -289,9 +465,9 @@ func TestOptionsRequest(t *testing.T) {
 }
 
 func TestOptionsRequestURLNotFound(t *testing.T) {
-	requestUrl := "https://httpbin.org/notfound"
+	requestUrl := newTestServer(t).URL + "/notfound"
 
-	results, err := OptionsRequest(requestUrl)
+	results, err := doRequest(OPTIONS, requestUrl, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
@@ -304,7 +480,175 @@ func TestOptionsRequestURLNotFound(t *testing.T) {
 func TestOptionsRequestWithInvalidURL(t *testing.T) {
 	requestUrl := "invalid-url"
 
-	_, err := OptionsRequest(requestUrl)
+	_, err := doRequest(OPTIONS, requestUrl, nil)
 
 	assert.Error(t, err)
 }
+
+func TestCustomMethodRequest(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/anything"
+
+	results, err := doRequest("propfind", requestUrl, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, results.StatusCode)
+	assert.Equal(t, "PROPFIND", echoed(t, results)["method"])
+}
+
+func TestRequestWithHeaders(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/get"
+	args := []string{"Authorization:Bearer token", "User-Agent:"}
+
+	results, err := doRequest(GET, requestUrl, args)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer token", results.RequestHeaders.Get("Authorization"))
+
+	headers := echoed(t, results)["headers"].(map[string]any)
+	assert.Equal(t, []any{"Bearer token"}, headers["Authorization"])
+	assert.NotContains(t, headers, "User-Agent")
+}
+
+func TestRequestWithQuery(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/get?page=1"
+	args := []string{"tag==a", "tag==b"}
+
+	results, err := doRequest(GET, requestUrl, args)
+
+	assert.NoError(t, err)
+	assert.Equal(t, requestUrl+"&tag=a&tag=b", results.URL)
+	assert.Equal(t, map[string]any{"page": []any{"1"}, "tag": []any{"a", "b"}}, echoed(t, results)["args"])
+}
+
+func TestRequestWithRawBody(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/post"
+	rawBody := &RawBody{
+		Body:          ReaderBody(strings.NewReader("streamed body")),
+		ContentLength: -1,
+		ContentType:   "text/plain",
+	}
+
+	spec, err := NewRequestSpec(POST, requestUrl, []string{"X-Trace:1"}, JSONMode, rawBody)
+	assert.NoError(t, err)
+
+	results, err := Do(context.Background(), spec)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "streamed body", echoed(t, results)["data"])
+	assert.Equal(t, "text/plain", results.RequestHeaders.Get("Content-Type"))
+}
+
+func TestRequestWithRawBodyAndItems(t *testing.T) {
+	rawBody := &RawBody{Body: BytesBody([]byte("{}")), ContentLength: 2, ContentType: ContentType}
+
+	_, err := NewRequestSpec(POST, "https://example.com", []string{"foo=bar"}, JSONMode, rawBody)
+
+	assert.ErrorIs(t, err, manyBodiesErrMsg)
+}
+
+func TestRequestWithForm(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/post"
+
+	spec, err := NewRequestSpec(POST, requestUrl, []string{"name=please", "tag=a", "tag=b", "expr=a=b&c"}, FormMode, nil)
+	assert.NoError(t, err)
+
+	results, err := Do(context.Background(), spec)
+
+	assert.NoError(t, err)
+	assert.Equal(t, FormContentType, results.RequestHeaders.Get("Content-Type"))
+	assert.Equal(t, map[string]any{
+		"name": []any{"please"},
+		"tag":  []any{"a", "b"},
+		"expr": []any{"a=b&c"},
+	}, echoed(t, results)["form"])
+}
+
+func TestRequestWithFormJSONField(t *testing.T) {
+	_, err := NewRequestSpec(POST, "https://example.com", []string{"count:=1"}, FormMode, nil)
+
+	assert.ErrorIs(t, err, formJSONErrMsg)
+}
+
+func TestRequestWithMultipart(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/post"
+	dir := t.TempDir()
+	avatar := filepath.Join(dir, "avatar.png")
+	notes := filepath.Join(dir, "notes")
+	assert.NoError(t, os.WriteFile(avatar, []byte("png"), 0o644))
+	assert.NoError(t, os.WriteFile(notes, []byte("notes"), 0o644))
+
+	// Files switch the form to multipart
+	args := []string{"name=please", "avatar@" + avatar, "notes@" + notes + ";type=text/markdown;filename=notes.md"}
+	spec, err := NewRequestSpec(POST, requestUrl, args, FormMode, nil)
+	assert.NoError(t, err)
+
+	// The body is sent twice to check that it can be reopened
+	for i := 0; i < 2; i++ {
+		results, err := Do(context.Background(), spec)
+
+		assert.NoError(t, err)
+		assert.Contains(t, results.RequestHeaders.Get("Content-Type"), "multipart/form-data; boundary=")
+		assert.Equal(t, map[string]any{"name": []any{"please"}}, echoed(t, results)["form"])
+		assert.Equal(t, map[string]any{
+			"avatar": map[string]any{"filename": "avatar.png", "content-type": "image/png", "content": "png"},
+			"notes":  map[string]any{"filename": "notes.md", "content-type": "text/markdown", "content": "notes"},
+		}, echoed(t, results)["files"])
+	}
+}
+
+func TestRequestWithFileFieldWithoutForm(t *testing.T) {
+	_, err := NewRequestSpec(POST, "https://example.com", []string{"avatar@avatar.png"}, JSONMode, nil)
+
+	assert.ErrorIs(t, err, fileFieldsErrMsg)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/delay?duration=1s"
+
+	spec, err := NewRequestSpec(GET, requestUrl, nil, JSONMode, nil)
+	assert.NoError(t, err)
+	spec.Options.Timeout = 50 * time.Millisecond
+
+	_, err = Do(context.Background(), spec)
+
+	assert.ErrorIs(t, err, timeoutErrMsg)
+}
+
+func TestRequestReadTimeout(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/stall"
+
+	spec, err := NewRequestSpec(GET, requestUrl, nil, JSONMode, nil)
+	assert.NoError(t, err)
+	spec.Options.ReadTimeout = 50 * time.Millisecond
+
+	_, err = Do(context.Background(), spec)
+
+	assert.ErrorIs(t, err, readTimeoutErrMsg)
+}
+
+func TestRequestReadTimeoutNotExpired(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/delay?duration=20ms"
+
+	spec, err := NewRequestSpec(GET, requestUrl, nil, JSONMode, nil)
+	assert.NoError(t, err)
+	spec.Options.ReadTimeout = time.Second
+
+	results, err := Do(context.Background(), spec)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, results.StatusCode)
+}
+
+func TestRequestCancelled(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/delay?duration=1s"
+
+	spec, err := NewRequestSpec(GET, requestUrl, nil, JSONMode, nil)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err = Do(ctx, spec)
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultRetryOn    = "5xx,429,connect-error"
+	DefaultRetryDelay = 200 * time.Millisecond
+	// MaxRetryDelay caps both the backoff and the Retry-After header
+	MaxRetryDelay = 30 * time.Second
+)
+
+// RetryPolicy tells when and how a failed request is sent again
+type RetryPolicy struct {
+	// Retries is the maximum number of retries, 0 disables them
+	Retries int
+	On      RetryOn
+	// Delay before the first retry, it doubles at every retry
+	Delay time.Duration
+}
+
+// RetryOn lists the failures that are retried
+type RetryOn struct {
+	StatusCodes []int
+	// StatusClasses holds the first digit of the retried status codes, e.g. 5 for 5xx
+	StatusClasses []int
+	ConnectError  bool
+	Timeout       bool
+}
+
+// Attempt records a single try of a request
+type Attempt struct {
+	Number int
+	// Delay waited before the attempt
+	Delay      time.Duration
+	StatusCode int
+	RespTime   int64
+	Err        error
+}
+
+func (attempt Attempt) MarshalJSON() ([]byte, error) {
+	var errText string
+	if attempt.Err != nil {
+		errText = attempt.Err.Error()
+	}
+	return json.Marshal(struct {
+		Number     int    `json:"attempt"`
+		Delay      string `json:"delay"`
+		StatusCode int    `json:"status-code,omitempty"`
+		Time       string `json:"time"`
+		Error      string `json:"error,omitempty"`
+	}{
+		Number:     attempt.Number,
+		Delay:      attempt.Delay.String(),
+		StatusCode: attempt.StatusCode,
+		Time:       strconv.FormatInt(attempt.RespTime, 10) + " ms",
+		Error:      errText,
+	})
+}
+
+// ParseRetryOn parses a comma separated list of conditions: status codes (429),
+// status classes (5xx), connect-error and timeout
+func ParseRetryOn(value string) (RetryOn, error) {
+	var retryOn RetryOn
+
+	for _, condition := range strings.Split(value, ",") {
+		condition = strings.ToLower(strings.TrimSpace(condition))
+
+		switch {
+		case condition == "":
+		case condition == "connect-error":
+			retryOn.ConnectError = true
+		case condition == "timeout":
+			retryOn.Timeout = true
+		case len(condition) == 3 && strings.HasSuffix(condition, "xx") && condition[0] >= '1' && condition[0] <= '5':
+			retryOn.StatusClasses = append(retryOn.StatusClasses, int(condition[0]-'0'))
+		default:
+			statusCode, err := strconv.Atoi(condition)
+			if err != nil || statusCode < 100 || statusCode > 599 {
+				return RetryOn{}, fmt.Errorf("%w: %s", invalidRetryOnErrMsg, condition)
+			}
+			retryOn.StatusCodes = append(retryOn.StatusCodes, statusCode)
+		}
+	}
+
+	return retryOn, nil
+}
+
+// Match reports whether a request that ended with results and err must be retried
+func (retryOn RetryOn) Match(results Results, err error) bool {
+	if err != nil {
+		return retryOn.ConnectError && isConnectError(err) ||
+			retryOn.Timeout && (errors.Is(err, timeoutErrMsg) || errors.Is(err, readTimeoutErrMsg))
+	}
+
+	for _, statusCode := range retryOn.StatusCodes {
+		if results.StatusCode == statusCode {
+			return true
+		}
+	}
+	for _, class := range retryOn.StatusClasses {
+		if results.StatusCode/100 == class {
+			return true
+		}
+	}
+	return false
+}
+
+// isConnectError reports whether err happened while connecting to the server
+func isConnectError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// Backoff returns the delay before the given retry: the Retry-After header if the server sent it,
+// otherwise an exponential backoff with jitter
+func (policy RetryPolicy) Backoff(retry int, headers http.Header) time.Duration {
+	if delay, ok := retryAfter(headers); ok {
+		return min(delay, MaxRetryDelay)
+	}
+
+	delay := policy.Delay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	for i := 1; i < retry && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, MaxRetryDelay)
+
+	// Wait a random time between half and the whole delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as a date
+func retryAfter(headers http.Header) (time.Duration, bool) {
+	value := headers.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// sleep waits for delay or until ctx is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
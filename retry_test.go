@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryOn(t *testing.T) {
+	retryOn, err := ParseRetryOn("5xx, 429,connect-error,timeout")
+
+	assert.NoError(t, err)
+	assert.Equal(t, RetryOn{
+		StatusCodes:   []int{429},
+		StatusClasses: []int{5},
+		ConnectError:  true,
+		Timeout:       true,
+	}, retryOn)
+}
+
+func TestParseRetryOnInvalid(t *testing.T) {
+	for _, value := range []string{"6xx", "42", "dns-error"} {
+		_, err := ParseRetryOn(value)
+
+		assert.ErrorIs(t, err, invalidRetryOnErrMsg, value)
+	}
+}
+
+func TestRetryOnMatch(t *testing.T) {
+	retryOn, err := ParseRetryOn(DefaultRetryOn)
+	assert.NoError(t, err)
+
+	assert.True(t, retryOn.Match(Results{StatusCode: http.StatusServiceUnavailable}, nil))
+	assert.True(t, retryOn.Match(Results{StatusCode: http.StatusTooManyRequests}, nil))
+	assert.False(t, retryOn.Match(Results{StatusCode: http.StatusNotFound}, nil))
+	assert.False(t, retryOn.Match(Results{StatusCode: http.StatusOK}, nil))
+
+	connectErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	assert.True(t, retryOn.Match(Results{}, connectErr))
+	assert.False(t, retryOn.Match(Results{}, timeoutErrMsg))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{Retries: 5, Delay: 100 * time.Millisecond}
+
+	for retry, maxDelay := range []time.Duration{100, 200, 400, 800} {
+		delay := policy.Backoff(retry+1, nil)
+
+		assert.GreaterOrEqual(t, delay, maxDelay*time.Millisecond/2)
+		assert.LessOrEqual(t, delay, maxDelay*time.Millisecond)
+	}
+
+	assert.LessOrEqual(t, policy.Backoff(100, nil), MaxRetryDelay)
+}
+
+func TestRetryBackoffRetryAfter(t *testing.T) {
+	policy := RetryPolicy{Retries: 1, Delay: 100 * time.Millisecond}
+
+	headers := http.Header{"Retry-After": []string{"3"}}
+	assert.Equal(t, 3*time.Second, policy.Backoff(1, headers))
+
+	headers.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.Equal(t, MaxRetryDelay, policy.Backoff(1, headers))
+}
+
+func TestRequestRetried(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/flaky?failures=2"
+
+	spec, err := NewRequestSpec(GET, requestUrl, nil, JSONMode, nil)
+	assert.NoError(t, err)
+	retryOn, err := ParseRetryOn(DefaultRetryOn)
+	assert.NoError(t, err)
+	spec.Options.Retry = RetryPolicy{Retries: 3, On: retryOn, Delay: time.Millisecond}
+
+	results, err := Do(context.Background(), spec)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, results.StatusCode)
+	assert.Len(t, results.Attempts, 3)
+	assert.Equal(t, http.StatusServiceUnavailable, results.Attempts[0].StatusCode)
+	assert.Equal(t, 3, results.Attempts[2].Number)
+}
+
+func TestRequestRetriesExhausted(t *testing.T) {
+	requestUrl := newTestServer(t).URL + "/flaky?failures=5"
+
+	spec, err := NewRequestSpec(GET, requestUrl, nil, JSONMode, nil)
+	assert.NoError(t, err)
+	retryOn, err := ParseRetryOn("503")
+	assert.NoError(t, err)
+	spec.Options.Retry = RetryPolicy{Retries: 2, On: retryOn, Delay: time.Millisecond}
+
+	results, err := Do(context.Background(), spec)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, results.StatusCode)
+	assert.Len(t, results.Attempts, 3)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// Recorder collects the outcome of the requests of a run
+type Recorder struct {
+	respTimes     *Histogram
+	errors        int
+	bytesReceived int64
+	statusCodes   map[int]int
+}
+
+// NewRecorder returns an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{respTimes: NewHistogram(), statusCodes: make(map[int]int)}
+}
+
+// Record adds response to the recorder
+func (recorder *Recorder) Record(response Response) {
+	if response.Err != nil {
+		recorder.errors++
+		return
+	}
+
+	recorder.RecordSample(NewSample(response.Index, response.Results), int64(len(response.Results.StrBody)))
+}
+
+// RecordSample adds a response read back as a sample, with a body of bytesReceived
+func (recorder *Recorder) RecordSample(sample Sample, bytesReceived int64) {
+	recorder.respTimes.Record(sample.Timings.Total + sample.Lag)
+	recorder.bytesReceived += bytesReceived
+	recorder.statusCodes[sample.StatusCode]++
+}
+
+// Summary is the statistical report of a run
+type Summary struct {
+	// Requests counts the completed requests, failed ones included
+	Requests      int
+	Errors        int
+	Elapsed       time.Duration
+	Throughput    float64
+	BytesReceived int64
+	StatusCodes   map[int]int
+	Min           time.Duration
+	Mean          time.Duration
+	StdDev        time.Duration
+	Median        time.Duration
+	P90           time.Duration
+	P95           time.Duration
+	P99           time.Duration
+	Max           time.Duration
+	// Histogram holds the response times, so that runs can be compared later
+	Histogram *Histogram
+}
+
+// Summary returns the report of the requests recorded during elapsed
+func (recorder *Recorder) Summary(elapsed time.Duration) Summary {
+	respTimes := recorder.respTimes
+	summary := Summary{
+		Requests:      int(respTimes.Count()) + recorder.errors,
+		Errors:        recorder.errors,
+		Elapsed:       elapsed,
+		BytesReceived: recorder.bytesReceived,
+		StatusCodes:   make(map[int]int, len(recorder.statusCodes)),
+		Min:           respTimes.Min(),
+		Mean:          respTimes.Mean(),
+		StdDev:        respTimes.StdDev(),
+		Median:        respTimes.ValueAtPercentile(50),
+		P90:           respTimes.ValueAtPercentile(90),
+		P95:           respTimes.ValueAtPercentile(95),
+		P99:           respTimes.ValueAtPercentile(99),
+		Max:           respTimes.Max(),
+		Histogram:     NewHistogram(),
+	}
+	summary.Histogram.Merge(respTimes)
+	for statusCode, count := range recorder.statusCodes {
+		summary.StatusCodes[statusCode] = count
+	}
+	if elapsed > 0 {
+		summary.Throughput = float64(summary.Requests) / elapsed.Seconds()
+	}
+
+	return summary
+}
+
+func (summary Summary) MarshalJSON() ([]byte, error) {
+	statusCodes := make(map[string]int, len(summary.StatusCodes))
+	for statusCode, count := range summary.StatusCodes {
+		statusCodes[strconv.Itoa(statusCode)] = count
+	}
+
+	return json.Marshal(struct {
+		Requests      int                `json:"requests"`
+		Errors        int                `json:"errors"`
+		Elapsed       float64            `json:"duration-ms"`
+		Throughput    float64            `json:"throughput"`
+		BytesReceived int64              `json:"bytes-received"`
+		StatusCodes   map[string]int     `json:"status-codes"`
+		RespTime      map[string]float64 `json:"response-time-ms"`
+		Histogram     *Histogram         `json:"histogram"`
+	}{
+		Requests:      summary.Requests,
+		Errors:        summary.Errors,
+		Elapsed:       milliseconds(summary.Elapsed),
+		Throughput:    summary.Throughput,
+		BytesReceived: summary.BytesReceived,
+		StatusCodes:   statusCodes,
+		RespTime: map[string]float64{
+			"min":    milliseconds(summary.Min),
+			"mean":   milliseconds(summary.Mean),
+			"stddev": milliseconds(summary.StdDev),
+			"median": milliseconds(summary.Median),
+			"p90":    milliseconds(summary.P90),
+			"p95":    milliseconds(summary.P95),
+			"p99":    milliseconds(summary.P99),
+			"max":    milliseconds(summary.Max),
+		},
+		Histogram: summary.Histogram,
+	})
+}
+
+func (summary *Summary) UnmarshalJSON(data []byte) error {
+	var serialised struct {
+		Requests      int                `json:"requests"`
+		Errors        int                `json:"errors"`
+		Elapsed       float64            `json:"duration-ms"`
+		Throughput    float64            `json:"throughput"`
+		BytesReceived int64              `json:"bytes-received"`
+		StatusCodes   map[string]int     `json:"status-codes"`
+		RespTime      map[string]float64 `json:"response-time-ms"`
+		Histogram     *Histogram         `json:"histogram"`
+	}
+	if err := json.Unmarshal(data, &serialised); err != nil {
+		return err
+	}
+
+	statusCodes := make(map[int]int, len(serialised.StatusCodes))
+	for code, count := range serialised.StatusCodes {
+		statusCode, err := strconv.Atoi(code)
+		if err != nil {
+			return err
+		}
+		statusCodes[statusCode] = count
+	}
+	if serialised.Histogram == nil {
+		serialised.Histogram = NewHistogram()
+	}
+
+	duration := func(milliseconds float64) time.Duration {
+		return time.Duration(milliseconds * float64(time.Millisecond))
+	}
+	*summary = Summary{
+		Requests:      serialised.Requests,
+		Errors:        serialised.Errors,
+		Elapsed:       duration(serialised.Elapsed),
+		Throughput:    serialised.Throughput,
+		BytesReceived: serialised.BytesReceived,
+		StatusCodes:   statusCodes,
+		Min:           duration(serialised.RespTime["min"]),
+		Mean:          duration(serialised.RespTime["mean"]),
+		StdDev:        duration(serialised.RespTime["stddev"]),
+		Median:        duration(serialised.RespTime["median"]),
+		P90:           duration(serialised.RespTime["p90"]),
+		P95:           duration(serialised.RespTime["p95"]),
+		P99:           duration(serialised.RespTime["p99"]),
+		Max:           duration(serialised.RespTime["max"]),
+		Histogram:     serialised.Histogram,
+	}
+	return nil
+}
+
+// milliseconds converts d to milliseconds with microsecond precision
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// WriteSummary writes summary as JSON to path
+func WriteSummary(path string, summary Summary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// PrintSummary prints summary as a table.
+// repetitions is 0 if the number of requests wasn't limited.
+func PrintSummary(summary Summary, repetitions int) {
+	requests := strconv.Itoa(summary.Requests)
+	if repetitions > 0 {
+		requests += "/" + strconv.Itoa(repetitions)
+	}
+
+	data := pterm.TableData{
+		{"Metric", "Value"},
+		{"Requests", requests},
+		{"Errors", strconv.Itoa(summary.Errors)},
+		{"Duration", summary.Elapsed.Round(time.Millisecond).String()},
+		{"Throughput", strconv.FormatFloat(summary.Throughput, 'f', 2, 64) + " req/s"},
+		{"Bytes received", strconv.FormatInt(summary.BytesReceived, 10)},
+		{"Min", FormatDuration(summary.Min)},
+		{"Mean", FormatDuration(summary.Mean)},
+		{"Std dev", FormatDuration(summary.StdDev)},
+		{"Median", FormatDuration(summary.Median)},
+		{"p90", FormatDuration(summary.P90)},
+		{"p95", FormatDuration(summary.P95)},
+		{"p99", FormatDuration(summary.P99)},
+		{"Max", FormatDuration(summary.Max)},
+	}
+
+	statusCodes := make([]int, 0, len(summary.StatusCodes))
+	for statusCode := range summary.StatusCodes {
+		statusCodes = append(statusCodes, statusCode)
+	}
+	sort.Ints(statusCodes)
+	for _, statusCode := range statusCodes {
+		data = append(data, []string{"Status " + strconv.Itoa(statusCode), strconv.Itoa(summary.StatusCodes[statusCode])})
+	}
+
+	pterm.Println("\n- Summary: ")
+	_ = pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
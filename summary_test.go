@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorderSummary(t *testing.T) {
+	recorder := NewRecorder()
+	for i := 1; i <= 100; i++ {
+		statusCode := 200
+		if i%10 == 0 {
+			statusCode = 503
+		}
+		recorder.Record(Response{Index: i, Results: Results{
+			StatusCode: statusCode,
+			StrBody:    "ok",
+			Timings:    Timings{Total: time.Duration(i) * time.Millisecond},
+		}})
+	}
+	recorder.Record(Response{Index: 101, Err: errors.New("connection refused")})
+
+	summary := recorder.Summary(2 * time.Second)
+	assert.Equal(t, 101, summary.Requests)
+	assert.Equal(t, 1, summary.Errors)
+	assert.InDelta(t, 50.5, summary.Throughput, 1e-9)
+	assert.Equal(t, int64(200), summary.BytesReceived)
+	assert.Equal(t, map[int]int{200: 90, 503: 10}, summary.StatusCodes)
+	assert.Equal(t, time.Millisecond, summary.Min)
+	assert.Equal(t, 100*time.Millisecond, summary.Max)
+	assert.Equal(t, 50500*time.Microsecond, summary.Mean)
+	// Percentiles are precise to 1/1024 of their value
+	assert.InEpsilon(t, float64(50*time.Millisecond), float64(summary.Median), 1e-3)
+	assert.InEpsilon(t, float64(90*time.Millisecond), float64(summary.P90), 1e-3)
+	assert.InEpsilon(t, float64(95*time.Millisecond), float64(summary.P95), 1e-3)
+	assert.InEpsilon(t, float64(99*time.Millisecond), float64(summary.P99), 1e-3)
+	assert.Equal(t, int64(100), summary.Histogram.Count())
+	assert.InDelta(t, float64(28866*time.Microsecond), float64(summary.StdDev), float64(time.Microsecond))
+}
+
+func TestRecorderSummaryEmpty(t *testing.T) {
+	summary := NewRecorder().Summary(0)
+	assert.Equal(t, 0, summary.Requests)
+	assert.Equal(t, time.Duration(0), summary.Max)
+	assert.Equal(t, 0.0, summary.Throughput)
+}
+
+func TestSummaryMarshalJSON(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Record(Response{Results: Results{StatusCode: 200, Timings: Timings{Total: 1500 * time.Microsecond}, Lag: 250 * time.Microsecond}})
+
+	data, err := json.Marshal(recorder.Summary(time.Second))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"requests": 1,
+		"errors": 0,
+		"duration-ms": 1000,
+		"throughput": 1,
+		"bytes-received": 0,
+		"status-codes": {"200": 1},
+		"response-time-ms": {"min": 1.75, "mean": 1.75, "stddev": 0, "median": 1.75, "p90": 1.75, "p95": 1.75, "p99": 1.75, "max": 1.75},
+		"histogram": {"unit": "us", "count": 1, "min": 1750, "max": 1750, "sum": 1750, "sum-squares": 3062500, "counts": [[1750, 1]]}
+	}`, string(data))
+}
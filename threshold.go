@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// ThresholdsExitCode is the exit code of a run that violated a threshold
+const ThresholdsExitCode = 99
+
+// thresholdOperators are matched longest first
+var thresholdOperators = []string{"<=", ">=", "<", ">"}
+
+// Threshold is an assertion on a statistic of a run, e.g. p95<300ms
+type Threshold struct {
+	// Metric is min, mean, median, max, stddev, pNN (any percentile, e.g. p99.9),
+	// error_rate, errors, requests or throughput. The errors are the failed requests and the 5xx responses.
+	Metric   string
+	Operator string
+	// Value is in milliseconds for response times, in percent for error_rate and in requests per second for throughput
+	Value float64
+}
+
+// ParseThreshold parses a metric, an operator and a value, e.g. p95<300ms, error_rate<1% or throughput>=100
+func ParseThreshold(value string) (Threshold, error) {
+	expression := strings.ReplaceAll(value, " ", "")
+
+	for _, operator := range thresholdOperators {
+		metric, limit, found := strings.Cut(expression, operator)
+		if !found {
+			continue
+		}
+
+		threshold := Threshold{Metric: strings.ToLower(metric), Operator: operator}
+		var err error
+		switch {
+		case threshold.IsResponseTime():
+			threshold.Value, err = parseMilliseconds(limit)
+		case threshold.Metric == "error_rate":
+			threshold.Value, err = strconv.ParseFloat(strings.TrimSuffix(limit, "%"), 64)
+		case threshold.Metric == "errors" || threshold.Metric == "requests":
+			threshold.Value, err = strconv.ParseFloat(limit, 64)
+		case threshold.Metric == "throughput":
+			threshold.Value, err = strconv.ParseFloat(strings.TrimSuffix(limit, "/s"), 64)
+		default:
+			err = fmt.Errorf("unknown metric %s", metric)
+		}
+		if err != nil {
+			return Threshold{}, fmt.Errorf("%w: %s", invalidThresholdErrMsg, value)
+		}
+		return threshold, nil
+	}
+
+	return Threshold{}, fmt.Errorf("%w: %s", invalidThresholdErrMsg, value)
+}
+
+// parseMilliseconds parses a duration, a number without unit is in milliseconds
+func parseMilliseconds(value string) (float64, error) {
+	if milliseconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return milliseconds, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return float64(d) / float64(time.Millisecond), nil
+}
+
+// IsResponseTime reports whether the metric is a response time
+func (threshold Threshold) IsResponseTime() bool {
+	switch threshold.Metric {
+	case "min", "mean", "avg", "median", "max", "stddev":
+		return true
+	}
+	_, ok := threshold.percentile()
+	return ok
+}
+
+// percentile returns the percentile of a pNN metric
+func (threshold Threshold) percentile() (float64, bool) {
+	if !strings.HasPrefix(threshold.Metric, "p") {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(threshold.Metric[1:], 64)
+	return p, err == nil && p >= 0 && p <= 100
+}
+
+// thresholdErrors counts the failed requests of summary and its responses with a 5xx status code,
+// a server that answers every request with an error mustn't pass an error_rate gate
+func thresholdErrors(summary Summary) int {
+	failed := summary.Errors
+	for statusCode, count := range summary.StatusCodes {
+		if statusCode >= 500 {
+			failed += count
+		}
+	}
+	return failed
+}
+
+// Actual returns the value of the metric in summary
+func (threshold Threshold) Actual(summary Summary) float64 {
+	switch threshold.Metric {
+	case "min":
+		return milliseconds(summary.Min)
+	case "mean", "avg":
+		return milliseconds(summary.Mean)
+	case "median":
+		return milliseconds(summary.Median)
+	case "max":
+		return milliseconds(summary.Max)
+	case "stddev":
+		return milliseconds(summary.StdDev)
+	case "error_rate":
+		if summary.Requests == 0 {
+			return 0
+		}
+		return float64(thresholdErrors(summary)) / float64(summary.Requests) * 100
+	case "errors":
+		return float64(thresholdErrors(summary))
+	case "requests":
+		return float64(summary.Requests)
+	case "throughput":
+		return summary.Throughput
+	}
+
+	p, _ := threshold.percentile()
+	return milliseconds(summary.Histogram.ValueAtPercentile(p))
+}
+
+// Check reports whether summary satisfies the threshold
+func (threshold Threshold) Check(summary Summary) bool {
+	actual := threshold.Actual(summary)
+	switch threshold.Operator {
+	case "<":
+		return actual < threshold.Value
+	case "<=":
+		return actual <= threshold.Value
+	case ">":
+		return actual > threshold.Value
+	default:
+		return actual >= threshold.Value
+	}
+}
+
+// FormatValue formats value in the unit of the metric
+func (threshold Threshold) FormatValue(value float64) string {
+	switch {
+	case threshold.IsResponseTime():
+		return strconv.FormatFloat(value, 'f', 3, 64) + " ms"
+	case threshold.Metric == "error_rate":
+		return strconv.FormatFloat(value, 'f', 2, 64) + "%"
+	case threshold.Metric == "throughput":
+		return strconv.FormatFloat(value, 'f', 2, 64) + " req/s"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (threshold Threshold) String() string {
+	return threshold.Metric + threshold.Operator + threshold.FormatValue(threshold.Value)
+}
+
+// CheckThresholds prints whether summary satisfies every threshold and reports whether they all passed
+func CheckThresholds(thresholds []Threshold, summary Summary) bool {
+	pterm.Println("\n- Thresholds: ")
+
+	passed := true
+	for _, threshold := range thresholds {
+		actual := threshold.FormatValue(threshold.Actual(summary))
+		if threshold.Check(summary) {
+			pterm.Println("  " + pterm.Green("pass") + " " + pterm.White(threshold) + " (" + pterm.Green(actual) + ")")
+		} else {
+			pterm.Println("  " + pterm.Red("fail") + " " + pterm.White(threshold) + " (" + pterm.Red(actual) + ")")
+			passed = false
+		}
+	}
+	return passed
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseThreshold(t *testing.T) {
+	for value, expected := range map[string]Threshold{
+		"p95<300ms":         {Metric: "p95", Operator: "<", Value: 300},
+		"p99.9 <= 1.5s":     {Metric: "p99.9", Operator: "<=", Value: 1500},
+		"mean<250":          {Metric: "mean", Operator: "<", Value: 250},
+		"error_rate<1%":     {Metric: "error_rate", Operator: "<", Value: 1},
+		"throughput>=100/s": {Metric: "throughput", Operator: ">=", Value: 100},
+		"ERRORS>0":          {Metric: "errors", Operator: ">", Value: 0},
+	} {
+		threshold, err := ParseThreshold(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, threshold, value)
+	}
+
+	for _, value := range []string{"", "p95", "p95=300ms", "p101<1s", "latency<1s", "p95<fast", "error_rate<x%"} {
+		_, err := ParseThreshold(value)
+		assert.ErrorIs(t, err, invalidThresholdErrMsg, value)
+	}
+}
+
+func TestThresholdCheck(t *testing.T) {
+	recorder := NewRecorder()
+	for i := 1; i <= 99; i++ {
+		recorder.Record(Response{Results: Results{StatusCode: 200, Timings: Timings{Total: time.Duration(i) * time.Millisecond}}})
+	}
+	recorder.Record(Response{Err: timeoutErrMsg})
+	summary := recorder.Summary(time.Second)
+
+	for value, passed := range map[string]bool{
+		"p95<300ms":      true,
+		"p95<90ms":       false,
+		"max<=99ms":      true,
+		"min>1ms":        false,
+		"error_rate<1%":  false,
+		"error_rate<=1%": true,
+		"errors<1":       false,
+		"requests>=100":  true,
+		"throughput>200": false,
+	} {
+		threshold, err := ParseThreshold(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, passed, threshold.Check(summary), value)
+	}
+}
+
+func TestThresholdServerErrors(t *testing.T) {
+	recorder := NewRecorder()
+	for _, statusCode := range []int{503, 503, 500, 404} {
+		recorder.Record(Response{Results: Results{StatusCode: statusCode, Timings: Timings{Total: time.Millisecond}}})
+	}
+	summary := recorder.Summary(time.Second)
+
+	// The 5xx responses count as errors, the 4xx ones don't
+	for value, passed := range map[string]bool{
+		"error_rate<1%":   false,
+		"error_rate<=75%": true,
+		"error_rate<75%":  false,
+		"errors<=3":       true,
+		"errors<3":        false,
+	} {
+		threshold, err := ParseThreshold(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, passed, threshold.Check(summary), value)
+	}
+}
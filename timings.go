@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// Timings breaks down the time spent by the last hop of a request
+type Timings struct {
+	DNSLookup    time.Duration
+	TCPConnect   time.Duration
+	TLSHandshake time.Duration
+	// TTFB is the time from the start of the request to the first byte of the response
+	TTFB time.Duration
+	// Transfer is the time spent reading the response
+	Transfer time.Duration
+	// Total includes the redirects
+	Total time.Duration
+}
+
+func (timings Timings) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		DNSLookup    string `json:"dns-lookup"`
+		TCPConnect   string `json:"tcp-connect"`
+		TLSHandshake string `json:"tls-handshake"`
+		TTFB         string `json:"ttfb"`
+		Transfer     string `json:"transfer"`
+		Total        string `json:"total"`
+	}{
+		DNSLookup:    FormatDuration(timings.DNSLookup),
+		TCPConnect:   FormatDuration(timings.TCPConnect),
+		TLSHandshake: FormatDuration(timings.TLSHandshake),
+		TTFB:         FormatDuration(timings.TTFB),
+		Transfer:     FormatDuration(timings.Transfer),
+		Total:        FormatDuration(timings.Total),
+	})
+}
+
+// UnmarshalJSON reads timings written by MarshalJSON, or as numbers of milliseconds
+func (timings *Timings) UnmarshalJSON(data []byte) error {
+	result := gjson.ParseBytes(data)
+	duration := func(name string) time.Duration {
+		d, _ := parseLogDuration(result.Get(name))
+		return d
+	}
+
+	*timings = Timings{
+		DNSLookup:    duration("dns-lookup"),
+		TCPConnect:   duration("tcp-connect"),
+		TLSHandshake: duration("tls-handshake"),
+		TTFB:         duration("ttfb"),
+		Transfer:     duration("transfer"),
+		Total:        duration("total"),
+	}
+	return nil
+}
+
+// FormatDuration formats d in milliseconds with microsecond precision
+func FormatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3f ms", float64(d)/float64(time.Millisecond))
+}
+
+// timingTracer collects the timings of a hop with httptrace.
+// The hooks can be called from other goroutines, e.g. by a dial that completes after the request.
+type timingTracer struct {
+	mu                sync.Mutex
+	start             time.Time
+	dnsStart          time.Time
+	dnsDone           time.Time
+	connectStart      time.Time
+	connectDone       time.Time
+	tlsStart          time.Time
+	tlsDone           time.Time
+	firstResponseByte time.Time
+}
+
+// Reset starts the timings of a new hop
+func (tracer *timingTracer) Reset() {
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+
+	tracer.start = time.Now()
+	tracer.dnsStart, tracer.dnsDone = time.Time{}, time.Time{}
+	tracer.connectStart, tracer.connectDone = time.Time{}, time.Time{}
+	tracer.tlsStart, tracer.tlsDone = time.Time{}, time.Time{}
+	tracer.firstResponseByte = time.Time{}
+}
+
+func (tracer *timingTracer) record(field *time.Time) {
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+
+	*field = time.Now()
+}
+
+// Trace returns the hooks that record the timings
+func (tracer *timingTracer) Trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tracer.record(&tracer.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tracer.record(&tracer.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			tracer.record(&tracer.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			tracer.record(&tracer.connectDone)
+		},
+		TLSHandshakeStart: func() {
+			tracer.record(&tracer.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tracer.record(&tracer.tlsDone)
+		},
+		GotFirstResponseByte: func() {
+			tracer.record(&tracer.firstResponseByte)
+		},
+	}
+}
+
+// Timings returns the timings of the hop whose response has been read at end
+func (tracer *timingTracer) Timings(requestStart time.Time, end time.Time) Timings {
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+
+	return Timings{
+		DNSLookup:    between(tracer.dnsStart, tracer.dnsDone),
+		TCPConnect:   between(tracer.connectStart, tracer.connectDone),
+		TLSHandshake: between(tracer.tlsStart, tracer.tlsDone),
+		TTFB:         between(tracer.start, tracer.firstResponseByte),
+		Transfer:     between(tracer.firstResponseByte, end),
+		Total:        end.Sub(requestStart),
+	}
+}
+
+// between returns the time elapsed from start to end, 0 if one of them is missing
+func between(start time.Time, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "1.234 ms", FormatDuration(1234*time.Microsecond))
+	assert.Equal(t, "0.000 ms", FormatDuration(0))
+}
+
+func TestTimingsJSON(t *testing.T) {
+	timings := Timings{DNSLookup: 1234 * time.Microsecond, TTFB: 5 * time.Millisecond, Total: 7500 * time.Microsecond}
+	data, err := json.Marshal(timings)
+	assert.NoError(t, err)
+
+	var decoded Timings
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, timings, decoded)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"total": 2.5}`), &decoded))
+	assert.Equal(t, Timings{Total: 2500 * time.Microsecond}, decoded)
+}
+
+func TestRequestTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(5 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	}))
+	t.Cleanup(server.Close)
+
+	spec, err := NewRequestSpec(GET, server.URL, nil, JSONMode, nil)
+	assert.NoError(t, err)
+	spec.Options.Client = server.Client()
+
+	results, err := Do(context.Background(), spec)
+
+	assert.NoError(t, err)
+	timings := results.Timings
+	assert.Positive(t, timings.TCPConnect)
+	assert.Positive(t, timings.TLSHandshake)
+	assert.GreaterOrEqual(t, timings.TTFB, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, timings.Transfer, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, timings.Total, timings.TTFB+timings.Transfer)
+	assert.Equal(t, timings.Total.Milliseconds(), results.RespTime)
+}